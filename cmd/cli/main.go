@@ -2,24 +2,57 @@ package main
 
 import (
 	"context"
-
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"brightedge-go-crawler/internal/classifier"
 	"brightedge-go-crawler/internal/crawler"
+	"brightedge-go-crawler/internal/dedup"
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/httpcache"
 	"brightedge-go-crawler/internal/ioformats"
-	"brightedge-go-crawler/internal/models"
 	"brightedge-go-crawler/internal/parser"
+	"brightedge-go-crawler/internal/pipeline"
+	"brightedge-go-crawler/internal/scheduler"
+	"brightedge-go-crawler/internal/urlnorm"
 )
 
 func main() {
 	in := flag.String("input", "", "input file (csv with 'url' column or ndjson)")
 	out := flag.String("output", "", "output NDJSON file (default stdout)")
 	concurrency := flag.Int("concurrency", 10, "worker concurrency")
+	perHost := flag.Int("per-host", 2, "max concurrent requests per host")
+	hostRate := flag.Float64("host-rate", 2, "max requests per second per host (0 = unlimited)")
+	retries := flag.Int("retries", 3, "max fetch attempts per URL for transient errors")
+	respectRobots := flag.Bool("robots", true, "honor robots.txt (disallowed URLs are reported as errors)")
+	oversize := flag.String("oversize", "truncate", "what to do with pages over the 5MB cap: truncate or fail")
+	links := flag.Bool("links", false, "include the full outgoing link list in each result")
+	contentMode := flag.String("content", "main", "text extraction: main (main content block, falling back to all) or all (every paragraph and list item)")
+	spider := flag.Bool("spider", false, "follow links from the input URLs")
+	maxDepth := flag.Int("max-depth", 2, "spider: max link depth from a seed")
+	scope := flag.String("scope", "host", "spider: follow links on the seed's host, domain, or matching --scope-regex (regex)")
+	scopeRegex := flag.String("scope-regex", "", "spider: URL pattern for --scope=regex")
+	maxPages := flag.Int("max-pages", 0, "spider: max pages per run (0 = unlimited)")
+	maxPagesPerHost := flag.Int("max-pages-per-host", 0, "spider: max pages per host (0 = unlimited)")
+	followNofollow := flag.Bool("follow-nofollow", false, "spider: also follow rel=nofollow/ugc/sponsored links")
+	stripParams := flag.String("strip-params", strings.Join(urlnorm.DefaultStripParams, ","), "comma-separated query params ignored when deduplicating URLs (\"prefix*\" allowed)")
+	dedupMode := flag.String("dedup", "exact", "skip repeated input URLs by normalized form: exact, bloom (fixed memory, may skip a few unique URLs) or off")
+	dedupFP := flag.Float64("dedup-fp", 0.001, "bloom: false-positive rate at --dedup-capacity URLs")
+	dedupCapacity := flag.Int("dedup-capacity", 10_000_000, "bloom: expected number of input URLs")
+	emitDuplicates := flag.Bool("emit-duplicates", false, "write a record with duplicateOf for every skipped duplicate")
+	ordered := flag.Bool("ordered", false, "write results in input order (buffers at most --order-window results)")
+	orderWindow := flag.Int("order-window", 1000, "ordered: max results held back waiting for an earlier URL")
+	flushEvery := flag.Duration("flush-interval", time.Second, "how often buffered output is flushed")
+	resume := flag.Bool("resume", false, "append to --output, skipping URLs it already has records for")
+	cacheDir := flag.String("cache-dir", "", "directory for the ETag/Last-Modified cache (empty disables conditional GETs)")
 	flag.Parse()
 
 	if *in == "" {
@@ -27,72 +60,207 @@ func main() {
 		os.Exit(2)
 	}
 
-	urls, err := ioformats.ReadURLs(*in)
+	input, err := ioformats.Open(*in)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "read input:", err)
 		os.Exit(1)
 	}
+	defer input.Close()
 
-	client := crawler.NewHTTPClient(15*time.Second, 5*time.Second, 5*1024*1024)
-	par := parser.New()
-	cl := classifier.New()
+	oversizePolicy, err := crawler.ParseOversizePolicy(*oversize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	mode, err := parser.ParseContentMode(*contentMode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	type outRec struct {
-		URL    string              `json:"url"`
-		Result *models.CrawlResult `json:"result,omitempty"`
-		Error  string              `json:"error,omitempty"`
+	spiderCfg := pipeline.SpiderConfig{
+		MaxDepth:        *maxDepth,
+		MaxPages:        *maxPages,
+		MaxPagesPerHost: *maxPagesPerHost,
+		FollowNofollow:  *followNofollow,
+	}
+	if spiderCfg.Scope, err = pipeline.ParseScope(*scope); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if spiderCfg.Scope == pipeline.ScopeRegex {
+		if spiderCfg.Pattern, err = regexp.Compile(*scopeRegex); err != nil || *scopeRegex == "" {
+			fmt.Fprintln(os.Stderr, "--scope=regex needs a valid --scope-regex")
+			os.Exit(2)
+		}
 	}
 
-	results := make([]outRec, len(urls))
+	var newSeen func() dedup.Set
+	switch *dedupMode {
+	case "exact":
+	case "bloom":
+		newSeen = func() dedup.Set { return dedup.NewBloom(*dedupCapacity, *dedupFP) }
+	case "off":
+		newSeen = func() dedup.Set { return dedup.Off{} }
+	default:
+		fmt.Fprintf(os.Stderr, "unknown --dedup %q (want exact, bloom or off)\n", *dedupMode)
+		os.Exit(2)
+	}
 
-	sem := make(chan struct{}, *concurrency)
-	done := make(chan int, len(urls))
+	norm := urlnorm.New(strings.Split(*stripParams, ",")...)
 
-	for i, u := range urls {
-		i, u := i, u
-		sem <- struct{}{} // acquire
-		go func() {
-			defer func() { <-sem; done <- i }()
-			body, finalURL, ct, fetchMs, err := client.Fetch(context.Background(), u)
-			if err != nil {
-				results[i] = outRec{URL: u, Error: err.Error()}
-				return
-			}
-			defer body.Close()
-			page, err := par.Extract(body, ct)
-			if err != nil {
-				results[i] = outRec{URL: u, Error: err.Error()}
-				return
-			}
-			cr := models.CrawlResult{
-				SourceURL: finalURL,
-				FetchMs:   fetchMs.Milliseconds(),
-				Meta:      page.Meta,
-				Content:   page.Content,
-				Class:     cl.Classify(page),
-				Topics:    cl.TopTopics(page.Content.Text, 15),
-			}
-			results[i] = outRec{URL: u, Result: &cr}
-		}()
+	var cache *httpcache.Cache
+	if *cacheDir != "" {
+		if cache, err = httpcache.Open(*cacheDir, norm); err != nil {
+			fmt.Fprintln(os.Stderr, "open cache:", err)
+			os.Exit(1)
+		}
+	}
+
+	client := crawler.NewHTTPClient(15*time.Second, 5*time.Second, 5*1024*1024,
+		crawler.WithRobots(*respectRobots),
+		crawler.WithRetry(retryPolicy(*retries)),
+		crawler.WithValidators(cache),
+		crawler.WithOversize(oversizePolicy),
+	)
+	pl := pipeline.New(pipeline.Config{
+		Client: client,
+		Cache:  cache,
+		Parser: parser.New(parser.WithContentMode(mode)),
+		Scheduler: scheduler.Config{
+			Workers:     *concurrency,
+			PerHost:     *perHost,
+			PerHostRate: *hostRate,
+			Burst:       *perHost,
+		},
+		Normalizer: norm,
+	})
+	pl.IncludeLinks = *links
+	// duplicates always reach emit so --ordered sees every index; they are
+	// dropped there unless --emit-duplicates
+	pl.Dedup, pl.EmitDuplicates = newSeen, true
+
+	if *ordered && *spider {
+		fmt.Fprintln(os.Stderr, "--ordered cannot be combined with --spider")
+		os.Exit(2)
 	}
-	for range urls {
-		<-done
+	if *resume && (*out == "" || *spider) {
+		fmt.Fprintln(os.Stderr, "--resume needs --output and cannot be combined with --spider")
+		os.Exit(2)
 	}
 
-	var w *os.File
-	if *out == "" {
-		w = os.Stdout
-	} else {
+	// completed holds the URLs already in the output when resuming. It is
+	// always exact: a false positive would skip a URL that never finished.
+	var completed dedup.Set
+	var resumed atomic.Int64
+	var dst io.Writer = os.Stdout
+	if *resume {
+		completed = dedup.NewExact()
+		f, err := ioformats.OpenAppend(*out, func(line []byte) {
+			var rec pipeline.Record
+			if json.Unmarshal(line, &rec) == nil && rec.URL != "" && rec.ErrorCode != fetcherr.CodeCanceled {
+				completed.Add(norm.Key(rec.URL))
+			}
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "open output:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		dst = f
+	} else if *out != "" {
 		f, err := os.Create(*out)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "create output:", err)
 			os.Exit(1)
 		}
 		defer f.Close()
-		w = f
+		dst = f
+	}
+	w := ioformats.NewNDJSONWriter(dst, *flushEvery)
+
+	// on SIGINT/SIGTERM stop fetching and keep what was written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	badLines := 0
+	urls, readErr := ioformats.Stream(ctx, input, func(le *ioformats.LineError) {
+		badLines++
+		fmt.Fprintln(os.Stderr, "skipping input", le)
+	})
+	if completed != nil {
+		urls = skipCompleted(ctx, urls, completed, norm, &resumed)
+	}
+	emit := func(rec pipeline.Record) {
+		// URLs cut off by an interrupt are not done; leave them for --resume
+		if ctx.Err() != nil && rec.ErrorCode == fetcherr.CodeCanceled {
+			return
+		}
+		if rec.DuplicateOf == "" || *emitDuplicates {
+			_ = w.Write(rec)
+		}
+	}
+	var ro *pipeline.Reorder
+	if *ordered {
+		ro = pipeline.NewReorder(*orderWindow, emit)
+		urls, emit = ro.Gate(ctx, urls), ro.Emit
+	}
+
+	var sum pipeline.Summary
+	if *spider {
+		sum = pl.Spider(ctx, urls, spiderCfg, emit)
+	} else {
+		sum = pl.Run(ctx, urls, emit)
+	}
+	if ro != nil {
+		ro.Flush()
 	}
-	enc := json.NewEncoder(w)
-	for _, r := range results {
-		_ = enc.Encode(r)
+	if completed != nil {
+		fmt.Fprintf(os.Stderr, "resume: skipped %d urls already in %s\n", resumed.Load(), *out)
 	}
+	failed := false
+	if err := <-readErr; err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "read input:", err)
+		failed = true
+	}
+	if err := w.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "write output:", err)
+		failed = true
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "interrupted")
+		failed = true
+	}
+	fmt.Fprintf(os.Stderr, "%d urls: %d succeeded, %d failed, %d duplicates skipped, %d bad input lines\n",
+		sum.Total, sum.Succeeded, sum.Failed, sum.Duplicates, badLines)
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// skipCompleted forwards the URLs of in that are not in completed and counts
+// the others in skipped.
+func skipCompleted(ctx context.Context, in <-chan string, completed dedup.Set, norm *urlnorm.Normalizer, skipped *atomic.Int64) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range in {
+			if completed.Has(norm.Key(u)) {
+				skipped.Add(1)
+				continue
+			}
+			select {
+			case out <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func retryPolicy(maxAttempts int) crawler.RetryPolicy {
+	p := crawler.DefaultRetryPolicy()
+	p.MaxAttempts = maxAttempts
+	return p
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"brightedge-go-crawler/internal/ioformats"
+	"brightedge-go-crawler/internal/jobs"
+	"brightedge-go-crawler/internal/pipeline"
+)
+
+// handleJobs registers the asynchronous job API on mux. store may be nil.
+func handleJobs(mux *http.ServeMux, m *jobs.Manager, store *jobs.Store) {
+	// POST /api/v1/jobs  { "urls": [...] } or multipart file=... -> 202 + status
+	mux.HandleFunc("POST /api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		spec, err := jobSpec(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		st, err := m.Submit(spec)
+		if err != nil {
+			if spec.File != "" {
+				os.Remove(spec.File)
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Location", "/api/v1/jobs/"+st.ID)
+		writeJSON(w, http.StatusAccepted, st)
+	})
+
+	mux.HandleFunc("GET /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		st, err := m.Status(r.PathValue("id"))
+		if err != nil {
+			writeJobErr(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+
+	// GET /api/v1/jobs/{id}/results?offset=0&limit=100  -> one page as JSON
+	// GET /api/v1/jobs/{id}/results?stream=true         -> NDJSON until the job ends
+	mux.HandleFunc("GET /api/v1/jobs/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if r.URL.Query().Get("stream") == "true" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+			if _, err := m.Status(id); err != nil {
+				writeJobErr(w, err)
+				return
+			}
+			// a stream lasts as long as the job, past the server's WriteTimeout
+			rc := http.NewResponseController(w)
+			_ = rc.SetWriteDeadline(time.Time{})
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			enc := json.NewEncoder(w)
+			_ = m.Follow(r.Context(), id, offset, func(rec pipeline.Record) error {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+				return rc.Flush()
+			})
+			return
+		}
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		recs, st, err := m.Results(id, offset, limit)
+		if err != nil {
+			writeJobErr(w, err)
+			return
+		}
+		resp := map[string]any{"jobId": id, "state": st.State, "offset": offset, "results": recs}
+		if next := offset + len(recs); next < st.Completed || !st.State.Finished() {
+			resp["next"] = next
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	// GET /api/v1/results?url=...  -> latest stored result for a URL, any job
+	mux.HandleFunc("GET /api/v1/results", func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "results are only kept with --data-dir"})
+			return
+		}
+		u := r.URL.Query().Get("url")
+		if u == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url parameter required"})
+			return
+		}
+		res, err := store.Lookup(u)
+		if errors.Is(err, jobs.ErrNoResult) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+
+	mux.HandleFunc("GET /api/v1/jobs/{id}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		ds, err := m.Deliveries(r.PathValue("id"))
+		if err != nil {
+			writeJobErr(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"jobId": r.PathValue("id"), "deliveries": ds})
+	})
+
+	mux.HandleFunc("DELETE /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		st, err := m.Cancel(r.PathValue("id"))
+		if err != nil {
+			writeJobErr(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+}
+
+// jobSpec reads a job from a JSON body like /crawl/batch, or from a
+// multipart upload like /crawl/upload, whose file is saved for the job.
+func jobSpec(r *http.Request) (jobs.Spec, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		var req batchReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.URLs) == 0 {
+			return jobs.Spec{}, errors.New("invalid payload")
+		}
+		return jobs.Spec{URLs: req.URLs, Oversize: req.Oversize, IncludeLinks: req.IncludeLinks, Spider: req.Spider, Callback: req.Callback}, nil
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return jobs.Spec{}, errors.New("multipart parse error")
+	}
+	f, hdr, err := r.FormFile("file")
+	if err != nil {
+		return jobs.Spec{}, errors.New("file part 'file' required")
+	}
+	defer f.Close()
+	tmp, err := os.CreateTemp("", "job-*")
+	if err != nil {
+		return jobs.Spec{}, err
+	}
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, f); err != nil {
+		os.Remove(tmp.Name())
+		return jobs.Spec{}, err
+	}
+	spec := jobs.Spec{
+		File:         tmp.Name(),
+		Format:       ioformats.FormatOf(hdr.Filename),
+		Oversize:     r.FormValue("oversize"),
+		IncludeLinks: r.FormValue("includeLinks") == "true",
+	}
+	if u := r.FormValue("callbackUrl"); u != "" {
+		batch, _ := strconv.Atoi(r.FormValue("callbackBatchSize"))
+		spec.Callback = &jobs.CallbackSpec{
+			URL:       u,
+			Secret:    r.FormValue("callbackSecret"),
+			Results:   r.FormValue("callbackResults") == "true",
+			BatchSize: batch,
+		}
+	}
+	return spec, nil
+}
+
+func writeJobErr(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, jobs.ErrFinished):
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
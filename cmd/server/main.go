@@ -4,36 +4,126 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"brightedge-go-crawler/internal/classifier"
 	"brightedge-go-crawler/internal/crawler"
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/httpcache"
 	"brightedge-go-crawler/internal/ioformats"
-	"brightedge-go-crawler/internal/models"
+	"brightedge-go-crawler/internal/jobs"
 	"brightedge-go-crawler/internal/parser"
+	"brightedge-go-crawler/internal/pipeline"
+	"brightedge-go-crawler/internal/scheduler"
+	"brightedge-go-crawler/internal/urlnorm"
+	"brightedge-go-crawler/internal/webhook"
 	"brightedge-go-crawler/pkg/logger"
 )
 
 type crawlReq struct {
-	URL string `json:"url"`
+	URL          string `json:"url"`
+	Oversize     string `json:"oversize,omitempty"` // "truncate" (default) or "fail"
+	IncludeLinks bool   `json:"includeLinks,omitempty"`
 }
 
 type batchReq struct {
-	URLs []string `json:"urls"`
+	URLs         []string           `json:"urls"`
+	Oversize     string             `json:"oversize,omitempty"`
+	IncludeLinks bool               `json:"includeLinks,omitempty"`
+	Spider       *jobs.SpiderSpec   `json:"spider,omitempty"`   // follow links from urls
+	Callback     *jobs.CallbackSpec `json:"callback,omitempty"` // jobs only
 }
 
+// maxSpiderPages caps every server spider job.
+const maxSpiderPages = 1000
+
 func main() {
+	cacheDir := flag.String("cache-dir", "", "directory for the ETag/Last-Modified cache (empty disables conditional GETs)")
+	allowPrivate := flag.Bool("allow-private", false, "allow crawling loopback, private, link-local and metadata addresses")
+	allowHosts := flag.String("allow-hosts", "", "comma-separated hosts, IPs or CIDRs always allowed (\".example.com\" matches subdomains)")
+	denyHosts := flag.String("deny-hosts", "", "comma-separated hosts, IPs or CIDRs always denied")
+	stripParams := flag.String("strip-params", strings.Join(urlnorm.DefaultStripParams, ","), "comma-separated query params ignored when deduplicating URLs (\"prefix*\" allowed)")
+	dataDir := flag.String("data-dir", "", "directory for jobs and results; unfinished jobs resume after a restart (empty keeps them in memory)")
+	hookAttempts := flag.Int("webhook-attempts", webhook.DefaultPolicy().MaxAttempts, "tries per job callback delivery, with exponential backoff from 1s up to 5m")
+	contentMode := flag.String("content", "main", "text extraction: main (main content block, falling back to all) or all (every paragraph and list item)")
+	maxJobs := flag.Int("max-jobs", 2, "jobs from /api/v1/jobs crawled at the same time; others wait queued")
+	keepJobs := flag.Int("keep-jobs", jobs.DefaultRetention, "finished jobs kept in memory without --data-dir; the oldest are dropped")
+	flag.Parse()
+
 	l := logger.New()
 	mux := http.NewServeMux()
+	norm := urlnorm.New(splitList(*stripParams)...)
 
-	client := crawler.NewHTTPClient(15*time.Second, 5*time.Second, 5*1024*1024) // 5MB cap
-	par := parser.New()
-	cl := classifier.New()
+	var cache *httpcache.Cache
+	if *cacheDir != "" {
+		var err error
+		if cache, err = httpcache.Open(*cacheDir, norm); err != nil {
+			l.Errorf("open cache: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	client := crawler.NewHTTPClient(15*time.Second, 5*time.Second, 5*1024*1024, // 5MB cap
+		crawler.WithValidators(cache),
+		crawler.WithNetPolicy(crawler.NetPolicy{
+			BlockPrivate: !*allowPrivate,
+			AllowHosts:   splitList(*allowHosts),
+			DenyHosts:    splitList(*denyHosts),
+		}),
+	)
+	mode, err := parser.ParseContentMode(*contentMode)
+	if err != nil {
+		l.Errorf("%v", err)
+		os.Exit(2)
+	}
+	pl := pipeline.New(pipeline.Config{
+		Client:     client,
+		Parser:     parser.New(parser.WithContentMode(mode)),
+		Cache:      cache,
+		Scheduler:  scheduler.DefaultConfig(),
+		Normalizer: norm,
+	})
+
+	// forRequest returns the pipeline configured with a request's options.
+	forRequest := func(oversize string, includeLinks bool) (*pipeline.Pipeline, error) {
+		policy, err := crawler.ParseOversizePolicy(oversize)
+		if err != nil {
+			return nil, err
+		}
+		rp := pl.WithClient(client.With(crawler.WithOversize(policy)))
+		rp.IncludeLinks = includeLinks
+		return rp, nil
+	}
+
+	var store *jobs.Store
+	if *dataDir != "" {
+		var err error
+		if store, err = jobs.OpenStore(*dataDir, norm); err != nil {
+			l.Errorf("open data dir: %v", err)
+			os.Exit(1)
+		}
+	}
+	hookPolicy := webhook.DefaultPolicy()
+	hookPolicy.MaxAttempts = *hookAttempts
+	jm, err := jobs.NewManager(func(spec jobs.Spec) (*pipeline.Pipeline, error) {
+		return forRequest(spec.Oversize, spec.IncludeLinks)
+	}, *maxJobs, maxSpiderPages, store,
+		// callbacks go through the crawl client, so the network policy applies to them too
+		jobs.WithWebhooks(webhook.NewSender(client, hookPolicy)),
+		jobs.WithRetention(*keepJobs),
+		jobs.WithNormalizer(norm))
+	if err != nil {
+		l.Errorf("load jobs: %v", err)
+		os.Exit(1)
+	}
+	handleJobs(mux, jm, store)
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
@@ -50,33 +140,18 @@ func main() {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payload"})
 			return
 		}
-
-		ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
-		defer cancel()
-
-		body, finalURL, ct, fetchMs, err := client.Fetch(ctx, req.URL)
+		pl, err := forRequest(req.Oversize, req.IncludeLinks)
 		if err != nil {
-			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		defer body.Close()
 
-		page, err := par.Extract(body, ct)
-		if err != nil {
-			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		rec := pl.Process(r.Context(), 0, req.URL)
+		if rec.Result == nil {
+			writeJSON(w, statusFor(rec.ErrorCode), rec)
 			return
 		}
-
-		result := models.CrawlResult{
-			SourceURL: finalURL,
-			FetchMs:   fetchMs.Milliseconds(),
-			Meta:      page.Meta,
-			Content:   page.Content,
-		}
-		result.Class = cl.Classify(page)
-		result.Topics = cl.TopTopics(page.Content.Text, 15)
-
-		writeJSON(w, http.StatusOK, result)
+		writeJSON(w, http.StatusOK, rec.Result)
 	})
 
 	// POST /crawl/batch  { "urls": ["https://...", "..."] }
@@ -90,56 +165,32 @@ func main() {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid payload"})
 			return
 		}
-
-		type out struct {
-			URL    string              `json:"url"`
-			Result *models.CrawlResult `json:"result,omitempty"`
-			Error  string              `json:"error,omitempty"`
+		pl, err := forRequest(req.Oversize, req.IncludeLinks)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
 		}
 
-		results := make([]out, len(req.URLs))
-
-		// bounded concurrency
-		sem := make(chan struct{}, 10)
-		done := make(chan int, len(req.URLs))
-
-		for i, u := range req.URLs {
-			i, u := i, u
-			sem <- struct{}{} // acquire
-			go func() {
-				defer func() { <-sem; done <- i }()
-				ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-				defer cancel()
-				if u == "" {
-					results[i] = out{URL: u, Error: "empty url"}
-					return
-				}
-				body, finalURL, ct, fetchMs, err := client.Fetch(ctx, u)
-				if err != nil {
-					results[i] = out{URL: u, Error: err.Error()}
-					return
-				}
-				defer body.Close()
-				page, err := par.Extract(body, ct)
-				if err != nil {
-					results[i] = out{URL: u, Error: err.Error()}
-					return
-				}
-				cr := models.CrawlResult{
-					SourceURL: finalURL,
-					FetchMs:   fetchMs.Milliseconds(),
-					Meta:      page.Meta,
-					Content:   page.Content,
-				}
-				cr.Class = cl.Classify(page)
-				cr.Topics = cl.TopTopics(page.Content.Text, 15)
-				results[i] = out{URL: u, Result: &cr}
-			}()
+		// duplicates keep their slot in the response, marked duplicateOf
+		pl.EmitDuplicates = true
+		if req.Spider == nil {
+			results := make([]pipeline.Record, len(req.URLs))
+			pl.Run(r.Context(), pipeline.Feed(r.Context(), req.URLs), func(rec pipeline.Record) {
+				results[rec.Index] = rec
+			})
+			writeJSON(w, http.StatusOK, results)
+			return
 		}
-		// wait
-		for range req.URLs {
-			<-done
+		cfg, err := req.Spider.Config(maxSpiderPages)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
 		}
+		var results []pipeline.Record
+		pl.Spider(r.Context(), pipeline.Feed(r.Context(), req.URLs), cfg, func(rec pipeline.Record) {
+			results = append(results, rec)
+		})
+		sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
 		writeJSON(w, http.StatusOK, results)
 	})
 
@@ -153,29 +204,21 @@ func main() {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "multipart parse error"})
 			return
 		}
-		f, _, err := r.FormFile("file")
+		pl, err := forRequest(r.FormValue("oversize"), r.FormValue("includeLinks") == "true")
 		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file part 'file' required"})
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		defer f.Close()
+		pl.EmitDuplicates = r.FormValue("emitDuplicates") == "true"
 
-		// copy to temp file to reuse format reader
-		tmp, err := os.CreateTemp("", "upload-*")
+		f, hdr, err := r.FormFile("file")
 		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "temp file error"})
-			return
-		}
-		if _, err := io.Copy(tmp, f); err != nil {
-			tmp.Close()
-			os.Remove(tmp.Name())
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "copy error"})
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file part 'file' required"})
 			return
 		}
-		tmp.Close()
-		defer os.Remove(tmp.Name())
+		defer f.Close()
 
-		urls, err := ioformats.ReadURLs(tmp.Name())
+		in, err := ioformats.NewReader(f, ioformats.FormatOf(hdr.Filename))
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
@@ -183,54 +226,21 @@ func main() {
 
 		w.Header().Set("Content-Type", "application/x-ndjson")
 		enc := json.NewEncoder(w)
-
-		type out struct {
-			URL    string              `json:"url"`
-			Result *models.CrawlResult `json:"result,omitempty"`
-			Error  string              `json:"error,omitempty"`
+		var mu sync.Mutex // bad lines are reported from the reader goroutine
+		write := func(rec pipeline.Record) {
+			mu.Lock()
+			defer mu.Unlock()
+			_ = enc.Encode(rec)
 		}
-
-		sem := make(chan struct{}, 10)
-		done := make(chan struct{})
-
-		go func() {
-			for _, u := range urls {
-				sem <- struct{}{} // acquire
-				u := u
-				go func() {
-					defer func() { <-sem }()
-					ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-					defer cancel()
-					body, finalURL, ct, fetchMs, err := client.Fetch(ctx, u)
-					if err != nil {
-						_ = enc.Encode(out{URL: u, Error: err.Error()})
-						return
-					}
-					defer body.Close()
-					page, err := par.Extract(body, ct)
-					if err != nil {
-						_ = enc.Encode(out{URL: u, Error: err.Error()})
-						return
-					}
-					cr := models.CrawlResult{
-						SourceURL: finalURL,
-						FetchMs:   fetchMs.Milliseconds(),
-						Meta:      page.Meta,
-						Content:   page.Content,
-					}
-					cr.Class = cl.Classify(page)
-					cr.Topics = cl.TopTopics(page.Content.Text, 15)
-					_ = enc.Encode(out{URL: u, Result: &cr})
-				}()
-			}
-			// wait for outstanding
-			for i := 0; i < cap(sem); i++ {
-				sem <- struct{}{} // fill to block until all released
-			}
-			close(done)
-		}()
-
-		<-done
+		urls, errc := ioformats.Stream(r.Context(), in, func(le *ioformats.LineError) {
+			write(pipeline.Record{URL: le.Text, Error: le.Error(), ErrorCode: fetcherr.CodeInvalidURL})
+		})
+		sum := pl.Run(r.Context(), urls, write)
+		if err := <-errc; err != nil {
+			l.Errorf("upload: read input: %v", err)
+		}
+		l.Infof("upload: %d urls, %d succeeded, %d failed, %d duplicates skipped",
+			sum.Total, sum.Succeeded, sum.Failed, sum.Duplicates)
 	})
 
 	addr := ":8080"
@@ -257,9 +267,33 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(ctx)
+	jm.Close()
 	l.Infof("bye")
 }
 
+// statusFor maps a failed /crawl record to an HTTP status.
+func statusFor(code fetcherr.Code) int {
+	switch code {
+	case fetcherr.CodeRobotsDenied, fetcherr.CodePolicy:
+		return http.StatusForbidden
+	case fetcherr.CodeInvalidURL:
+		return http.StatusBadRequest
+	case fetcherr.CodeDecode:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadGateway
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 func writeJSON(w http.ResponseWriter, code int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -4,6 +4,8 @@ go 1.22
 
 require (
 	github.com/PuerkitoBio/goquery v1.9.2
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.18.0
 	golang.org/x/net v0.33.0
 )
 
@@ -21,15 +21,15 @@ func TestAmazonProductPage(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
 	defer cancel()
 
-	body, _, ct, _, err := client.Fetch(ctx, url)
+	resp, err := client.Fetch(ctx, url)
 	if err != nil {
 		t.Skipf("skipping: fetch failed due to network/robots/captcha: %v", err)
 		return
 	}
-	defer body.Close()
+	defer resp.Body.Close()
 
 	p := parser.New()
-	page, err := p.Extract(body, ct)
+	page, err := p.Extract(resp.Body, resp.ContentType)
 	if err != nil {
 		t.Skipf("skipping: parse failed: %v", err)
 		return
@@ -40,7 +40,7 @@ func TestAmazonProductPage(t *testing.T) {
 	if class.Label != "product" {
 		t.Errorf("expected product class, got %s", class.Label)
 	}
-	if len(cl.TopTopics(page.Content.Text, 10)) == 0 {
+	if len(cl.TopTopics(page.Content.Text, page.Content.Language, 10)) == 0 {
 		t.Errorf("expected non-empty topics")
 	}
 }
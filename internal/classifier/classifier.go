@@ -7,6 +7,7 @@ import (
 	"strings"
 	"unicode"
 
+	"brightedge-go-crawler/internal/langid"
 	"brightedge-go-crawler/internal/models"
 )
 
@@ -25,6 +26,41 @@ var priceRe = regexp.MustCompile(`[$€£₹]\s?\d`)
 var cartRe = regexp.MustCompile(`(?i)add\s+to\s+cart|buy\s+now|checkout`)
 var articleRe = regexp.MustCompile(`(?i)author|byline|published|updated|minutes\s+read|subscribe`)
 
+// cartCues and articleCues are cartRe and articleRe for other languages,
+// used on top of the English ones when the page is detected as such.
+var cartCues = map[string]*regexp.Regexp{
+	"de": regexp.MustCompile(`(?i)in\s+den\s+warenkorb|jetzt\s+kaufen|zur\s+kasse`),
+	"fr": regexp.MustCompile(`(?i)ajouter\s+au\s+panier|acheter\s+maintenant|commander`),
+	"es": regexp.MustCompile(`(?i)añadir\s+al\s+carrito|agregar\s+al\s+carrito|comprar\s+ahora`),
+	"it": regexp.MustCompile(`(?i)aggiungi\s+al\s+carrello|acquista\s+ora|compra\s+ora`),
+	"pt": regexp.MustCompile(`(?i)adicionar\s+ao\s+carrinho|comprar\s+agora|finalizar\s+compra`),
+	"nl": regexp.MustCompile(`(?i)in\s+winkelwagen|nu\s+kopen|afrekenen`),
+	"sv": regexp.MustCompile(`(?i)lägg\s+i\s+varukorgen|köp\s+nu|till\s+kassan`),
+	"pl": regexp.MustCompile(`(?i)dodaj\s+do\s+koszyka|kup\s+teraz`),
+	"tr": regexp.MustCompile(`(?i)sepete\s+ekle|hemen\s+al|satın\s+al`),
+}
+
+var articleCues = map[string]*regexp.Regexp{
+	"de": regexp.MustCompile(`(?i)veröffentlicht|aktualisiert|autor|minuten\s+lesezeit`),
+	"fr": regexp.MustCompile(`(?i)publié|mis\s+à\s+jour|auteur|minutes\s+de\s+lecture`),
+	"es": regexp.MustCompile(`(?i)publicado|actualizado|autor|minutos\s+de\s+lectura`),
+	"it": regexp.MustCompile(`(?i)pubblicato|aggiornato|autore|minuti\s+di\s+lettura`),
+	"pt": regexp.MustCompile(`(?i)publicado|atualizado|autor|minutos\s+de\s+leitura`),
+	"nl": regexp.MustCompile(`(?i)gepubliceerd|bijgewerkt|auteur|minuten\s+leestijd`),
+	"sv": regexp.MustCompile(`(?i)publicerad|uppdaterad|författare|minuters\s+läsning`),
+	"pl": regexp.MustCompile(`(?i)opublikowano|zaktualizowano|autor|minut\s+czytania`),
+	"tr": regexp.MustCompile(`(?i)yayınlanma|güncellenme|yazar|dakikalık\s+okuma`),
+}
+
+// matches reports whether text matches re or the cue for lang.
+func matches(text, lang string, re *regexp.Regexp, cues map[string]*regexp.Regexp) bool {
+	if re.FindStringIndex(text) != nil {
+		return true
+	}
+	cue, ok := cues[lang]
+	return ok && cue.FindStringIndex(text) != nil
+}
+
 func (c *Classifier) Classify(p models.Page) models.Classification {
 	text := strings.ToLower(p.Content.Text + " " + strings.Join(p.Content.Headings, " "))
 	reason := map[string]string{}
@@ -33,19 +69,36 @@ func (c *Classifier) Classify(p models.Page) models.Classification {
 	if priceRe.FindStringIndex(text) != nil {
 		reason["price"] = "currency-like price detected"
 	}
-	if cartRe.FindStringIndex(text) != nil {
+	lang := langid.Primary(p.Content.Language)
+	if matches(text, lang, cartRe, cartCues) {
 		reason["cart"] = "ecommerce CTA found"
 	}
 	if _, ok := p.Meta.OG["og:type"]; ok && strings.Contains(strings.ToLower(p.Meta.OG["og:type"]), "product") {
 		reason["og:type"] = "og:type indicates product"
 	}
+	if p.Structured.Product != nil {
+		reason["schema"] = "schema.org Product markup"
+	}
 	if len(reason) > 0 {
 		return models.Classification{Label: "product", Reason: reason}
 	}
 
+	// schema.org article types are explicit about news vs blog
+	if a := p.Structured.Article; a != nil {
+		reason["schema"] = "schema.org " + a.Type + " markup"
+		if strings.Contains(a.Type, "Blog") {
+			return models.Classification{Label: "blog", Reason: reason}
+		}
+		return models.Classification{Label: "news", Reason: reason}
+	}
+
 	// news signals
+	if pub := p.Meta.PublishedAt; pub != nil && pub.Confidence >= 0.7 && len(p.Meta.Authors) > 0 {
+		reason["published"] = "publish date (" + pub.Source + ") and author found"
+		return models.Classification{Label: "news", Reason: reason}
+	}
 	if strings.Contains(strings.ToLower(p.Meta.OG["og:type"]), "article") ||
-		articleRe.FindStringIndex(text) != nil {
+		matches(text, lang, articleRe, articleCues) {
 		reason["article"] = "article-like markers"
 		return models.Classification{Label: "news", Reason: reason}
 	}
@@ -59,8 +112,10 @@ func (c *Classifier) Classify(p models.Page) models.Classification {
 	return models.Classification{Label: "other", Reason: reason}
 }
 
-// TopTopics returns top N keywords by normalized frequency, ignoring stopwords and short tokens.
-func (c *Classifier) TopTopics(text string, n int) []string {
+// TopTopics returns top N keywords by normalized frequency, ignoring short
+// tokens and the stopwords of English and of lang, the text's language.
+func (c *Classifier) TopTopics(text, lang string, n int) []string {
+	langStops := langid.Stopwords(langid.Primary(lang))
 	freq := map[string]int{}
 	token := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) }
 	words := strings.FieldsFunc(strings.ToLower(text), token)
@@ -72,6 +127,9 @@ func (c *Classifier) TopTopics(text string, n int) []string {
 		if _, stop := stopwords[w]; stop {
 			continue
 		}
+		if _, stop := langStops[w]; stop {
+			continue
+		}
 		freq[w]++
 	}
 
@@ -19,8 +19,27 @@ func TestClassify(t *testing.T) {
 	if c2.Label != "news" {
 		t.Fatalf("want news, got %s", c2.Label)
 	}
-	topics := cl.TopTopics("go go network network network parsing parsing", 3)
+	blog := models.Page{Structured: models.Structured{Article: &models.Article{Type: "BlogPosting"}}}
+	if c := cl.Classify(blog); c.Label != "blog" || c.Reason["schema"] == "" {
+		t.Fatalf("want blog from schema.org markup, got %+v", c)
+	}
+	dated := models.Page{Meta: models.Meta{
+		PublishedAt: &models.DateInfo{Value: "2024-05-01T00:00:00Z", Source: "meta:article:published_time", Confidence: 0.9},
+		Authors:     []models.Author{{Name: "Jane Roe", Source: "byline", Confidence: 0.6}},
+	}}
+	if c := cl.Classify(dated); c.Label != "news" || c.Reason["published"] == "" {
+		t.Fatalf("want news from publish date and author, got %+v", c)
+	}
+	topics := cl.TopTopics("go go network network network parsing parsing", "en", 3)
 	if len(topics) == 0 || topics[0] != "network" {
 		t.Fatalf("unexpected topics: %#v", topics)
 	}
+	shop := models.Page{Content: models.Content{Text: "Jetzt für 10 € in den Warenkorb legen.", Language: "de"}}
+	if c := cl.Classify(shop); c.Label != "product" {
+		t.Fatalf("want product from German cart cue, got %+v", c)
+	}
+	topics = cl.TopTopics("die Straße und die Schule, die Straße und der Haushalt, die Straße", "de", 2)
+	if len(topics) != 2 || topics[0] != "straße" {
+		t.Fatalf("unexpected German topics: %#v", topics)
+	}
 }
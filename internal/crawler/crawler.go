@@ -2,7 +2,6 @@
 package crawler
 
 import (
-	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
@@ -10,82 +9,420 @@ import (
 	"mime"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
 	"time"
+
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/models"
+	"brightedge-go-crawler/internal/robots"
 )
 
+const defaultUserAgent = "brightedge-go-crawler/1.0 (+https://example.com)"
+
 type HTTPClient struct {
-	client     *http.Client
-	sizeCap    int64
-	userAgent  string
+	client        *http.Client
+	sizeCap       int64
+	userAgent     string
+	respectRobots bool
+	robots        *robots.Cache
+	retry         RetryPolicy
+	validators    ValidatorSource
+	decoders      map[string]Decoder
+	encodings     []string // decoder names in Accept-Encoding order
+	maxRatio      float64
+	oversize      OversizePolicy
+	policy        *netPolicy
+}
+
+// Option configures an HTTPClient.
+type Option func(*HTTPClient)
+
+// WithUserAgent overrides the User-Agent sent with every request, including robots.txt.
+func WithUserAgent(ua string) Option {
+	return func(h *HTTPClient) { h.userAgent = ua }
+}
+
+// WithRobots turns robots.txt enforcement on or off (on by default).
+func WithRobots(enabled bool) Option {
+	return func(h *HTTPClient) { h.respectRobots = enabled }
+}
+
+// ValidatorSource supplies the ETag and Last-Modified seen on an earlier
+// fetch of a URL so Fetch can make a conditional request.
+type ValidatorSource interface {
+	Validators(rawURL string) (etag, lastModified string)
+}
+
+// WithValidators enables conditional GETs using v (typically an *httpcache.Cache).
+func WithValidators(v ValidatorSource) Option {
+	return func(h *HTTPClient) { h.validators = v }
+}
+
+// OversizePolicy decides what Fetch does with a body larger than the size cap.
+type OversizePolicy int
+
+const (
+	// OversizeTruncate passes the first sizeCap bytes through and marks the response truncated.
+	OversizeTruncate OversizePolicy = iota
+	// OversizeFail fails with a too_large error.
+	OversizeFail
+)
+
+// ParseOversizePolicy accepts "truncate" (or "") and "fail".
+func ParseOversizePolicy(s string) (OversizePolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "truncate":
+		return OversizeTruncate, nil
+	case "fail":
+		return OversizeFail, nil
+	}
+	return 0, fmt.Errorf("unknown oversize policy %q (want truncate or fail)", s)
 }
 
-func NewHTTPClient(timeout, dialTimeout time.Duration, sizeCap int64) *HTTPClient {
+// WithOversize sets the policy for bodies over the size cap (truncate by default).
+func WithOversize(p OversizePolicy) Option {
+	return func(h *HTTPClient) { h.oversize = p }
+}
+
+func NewHTTPClient(timeout, dialTimeout time.Duration, sizeCap int64, opts ...Option) *HTTPClient {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   dialTimeout,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
 		MaxIdleConns:        100,
 		IdleConnTimeout:     90 * time.Second,
 		TLSHandshakeTimeout: 10 * time.Second,
 	}
-	return &HTTPClient{
+	h := &HTTPClient{
 		client: &http.Client{
 			Transport: transport,
 			Timeout:   timeout,
 		},
-		sizeCap:   sizeCap,
-		userAgent: "brightedge-go-crawler/1.0 (+https://example.com)",
+		sizeCap:       sizeCap,
+		userAgent:     defaultUserAgent,
+		respectRobots: true,
+		retry:         DefaultRetryPolicy(),
+		decoders:      map[string]Decoder{},
+		maxRatio:      100,
+	}
+	for _, d := range defaultDecoders {
+		h.setDecoder(d.name, d.dec)
+	}
+	h.apply(opts)
+
+	policy := h.policy
+	if policy != nil {
+		transport.Proxy = nil
+		transport.DialContext = policy.dialContext(dialer)
+	}
+	h.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := recordRedirect(req, via); err != nil {
+			return err
+		}
+		if policy != nil {
+			_, err := policy.checkHost(req.URL.Hostname())
+			return err
+		}
+		return nil
 	}
+	return h
 }
 
-func (h *HTTPClient) Fetch(ctx context.Context, rawURL string) (io.ReadCloser, string, string, time.Duration, error) {
+// With returns a copy of h with opts applied, e.g. to change the oversize
+// policy for one job. The copy shares the connection pool and robots cache.
+func (h *HTTPClient) With(opts ...Option) *HTTPClient {
+	c := *h
+	c.decoders = make(map[string]Decoder, len(h.decoders))
+	for k, v := range h.decoders {
+		c.decoders[k] = v
+	}
+	c.encodings = append([]string(nil), h.encodings...)
+	c.apply(opts)
+	c.policy = h.policy // baked into the shared transport
+	return &c
+}
+
+func (h *HTTPClient) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(h)
+	}
+	switch {
+	case !h.respectRobots:
+		h.robots = nil
+	case h.robots == nil:
+		h.robots = robots.NewCache(h.client, h.userAgent, 24*time.Hour)
+	}
+}
+
+// CrawlDelay returns the robots.txt Crawl-delay for rawURL's host if it has
+// already been fetched, or zero.
+func (h *HTTPClient) CrawlDelay(rawURL string) time.Duration {
+	if h.robots == nil {
+		return 0
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return 0
+	}
+	return h.robots.CrawlDelay(u)
+}
+
+// Redirect is one hop followed while fetching.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+// Response is a successful fetch. The caller must close Body.
+type Response struct {
+	Body          io.ReadCloser
+	URL           string // final URL after redirects
+	StatusCode    int
+	Header        http.Header
+	ContentLength int64 // from the Content-Length header, -1 if unknown
+	ContentType   string
+	Charset       string
+	Redirects     []Redirect
+	RemoteIP      string
+	Elapsed       time.Duration
+	NotModified   bool // 304 to a conditional request; Body is empty
+
+	limit *capReader
+}
+
+// Truncated reports whether the body was cut at the size cap. It is only
+// meaningful once Body has been read to EOF.
+func (r *Response) Truncated() bool {
+	return r.limit != nil && r.limit.truncated
+}
+
+// DecodedLength returns the size of the decoded body as declared by the
+// server. Content-Length only gives it for bodies sent without a
+// Content-Encoding; otherwise, or if unknown, it is -1.
+func (r *Response) DecodedLength() int64 {
+	if enc := strings.TrimSpace(r.Header.Get("Content-Encoding")); enc != "" && !strings.EqualFold(enc, "identity") {
+		return -1
+	}
+	return r.ContentLength
+}
+
+// HTTPInfo returns the response metadata in its output form.
+func (r *Response) HTTPInfo() models.HTTPInfo {
+	info := models.HTTPInfo{
+		Status:        r.StatusCode,
+		Headers:       r.Header,
+		ContentLength: r.ContentLength,
+		ContentType:   r.ContentType,
+		Charset:       r.Charset,
+		RemoteIP:      r.RemoteIP,
+	}
+	for _, rd := range r.Redirects {
+		info.Redirects = append(info.Redirects, models.Redirect{URL: rd.URL, Status: rd.StatusCode, Location: rd.Location})
+	}
+	return info
+}
+
+type redirectsKey struct{}
+
+// recordRedirect is the client's CheckRedirect hook. It appends each hop to
+// the slice stored in the request context and keeps net/http's 10-hop limit.
+func recordRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if hops, ok := req.Context().Value(redirectsKey{}).(*[]Redirect); ok && req.Response != nil {
+		*hops = append(*hops, Redirect{
+			URL:        via[len(via)-1].URL.String(),
+			StatusCode: req.Response.StatusCode,
+			Location:   req.Response.Header.Get("Location"),
+		})
+	}
+	return nil
+}
+
+// body closes both the decoding reader and the underlying response body.
+type body struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *body) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Fetch downloads rawURL. Every error it returns is a *fetcherr.Error.
+func (h *HTTPClient) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	resp, err := h.fetch(ctx, rawURL)
+	if err != nil {
+		fe := fetcherr.Classify(err)
+		if fe.URL == "" {
+			fe.URL = rawURL
+		}
+		return nil, fe
+	}
+	return resp, nil
+}
+
+func (h *HTTPClient) fetch(ctx context.Context, rawURL string) (*Response, error) {
 	start := time.Now()
 	u, err := url.Parse(rawURL)
 	if err != nil || u.Scheme == "" || u.Host == "" {
-		return nil, "", "", 0, fmt.Errorf("invalid url")
+		return nil, fetcherr.Errorf(fetcherr.CodeInvalidURL, "invalid url")
+	}
+	if h.policy != nil {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, blocked("scheme %s is not allowed", u.Scheme)
+		}
+		if _, err := h.policy.checkHost(u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	if h.robots != nil {
+		ok, err := h.robots.Allowed(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fetcherr.Robots(u.String())
+		}
 	}
+
+	var hops []Redirect
+	var remoteIP string
+	ctx = context.WithValue(ctx, redirectsKey{}, &hops)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				remoteIP = host
+			}
+		},
+	})
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return nil, "", "", 0, err
+		return nil, fetcherr.New(fetcherr.CodeInvalidURL, err)
 	}
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Accept-Encoding", h.acceptEncoding())
 	req.Header.Set("User-Agent", h.userAgent)
+	conditional := false
+	if h.validators != nil {
+		etag, lastModified := h.validators.Validators(rawURL)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		conditional = etag != "" || lastModified != ""
+	}
 
 	resp, err := h.client.Do(req)
 	if err != nil {
-		return nil, "", "", 0, err
+		return nil, err
 	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+	if resp.StatusCode == http.StatusNotModified && conditional {
 		resp.Body.Close()
-		return nil, "", "", 0, fmt.Errorf("http status %d", resp.StatusCode)
+		return &Response{
+			Body:          http.NoBody,
+			URL:           resp.Request.URL.String(),
+			StatusCode:    resp.StatusCode,
+			Header:        resp.Header,
+			ContentLength: resp.ContentLength,
+			Redirects:     hops,
+			RemoteIP:      remoteIP,
+			Elapsed:       time.Since(start),
+			NotModified:   true,
+		}, nil
 	}
-
-	var body io.ReadCloser = resp.Body
-	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-		gz, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			resp.Body.Close()
-			return nil, "", "", 0, err
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		resp.Body.Close()
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
 		}
-		body = gz
+		return nil, fetcherr.Status(resp.StatusCode, retryAfter)
 	}
 
-	// enforce a size cap
-	r := io.LimitReader(body, h.sizeCap)
 	contentType := resp.Header.Get("Content-Type")
-	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType, params, _ := mime.ParseMediaType(contentType)
 	if !strings.Contains(mediaType, "text/html") && !strings.Contains(mediaType, "application/xhtml+xml") && mediaType != "" {
 		// still allow if empty (some servers omit), otherwise reject non-html
-		body.Close()
-		return nil, "", "", 0, errors.New("non-html content")
+		resp.Body.Close()
+		return nil, fetcherr.Errorf(fetcherr.CodeNonHTML, "non-html content")
 	}
 
-	finalURL := resp.Request.URL.String()
-	elapsed := time.Since(start)
-	return io.NopCloser(r), finalURL, contentType, elapsed, nil
+	if h.oversize == OversizeFail && resp.ContentLength > h.sizeCap {
+		resp.Body.Close()
+		return nil, fetcherr.Errorf(fetcherr.CodeTooLarge, "content-length %d exceeds size cap %d", resp.ContentLength, h.sizeCap)
+	}
+	decoded, closers, err := h.decode(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	// enforce a size cap on the decoded bytes
+	limit := &capReader{r: decoded, remaining: h.sizeCap, cap: h.sizeCap, fail: h.oversize == OversizeFail}
+	b := &body{
+		Reader:  limit,
+		closers: append(closers, resp.Body),
+	}
+
+	return &Response{
+		Body:          b,
+		URL:           resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		ContentType:   contentType,
+		Charset:       params["charset"],
+		Redirects:     hops,
+		RemoteIP:      remoteIP,
+		Elapsed:       time.Since(start),
+		limit:         limit,
+	}, nil
+}
+
+// capReader stops after cap bytes and notes whether anything was left over.
+// With fail set, leftover bytes turn into a too_large error instead of EOF.
+type capReader struct {
+	r         io.Reader
+	remaining int64
+	cap       int64
+	fail      bool
+	truncated bool
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		var probe [1]byte
+		if n, err := io.ReadFull(c.r, probe[:]); n == 0 {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		c.truncated = true
+		if c.fail {
+			return 0, fetcherr.Errorf(fetcherr.CodeTooLarge, "body exceeds size cap %d", c.cap)
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
 }
@@ -2,11 +2,23 @@
 package crawler
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"brightedge-go-crawler/internal/fetcherr"
 )
 
 func TestFetchHTML(t *testing.T) {
@@ -18,12 +30,12 @@ func TestFetchHTML(t *testing.T) {
 	defer ts.Close()
 
 	client := NewHTTPClient(5*time.Second, 2*time.Second, 1024)
-	rc, final, ct, dur, err := client.Fetch(context.Background(), ts.URL)
+	resp, err := client.Fetch(context.Background(), ts.URL)
 	if err != nil {
 		t.Fatalf("fetch err: %v", err)
 	}
-	defer rc.Close()
-	if final == "" || ct == "" || dur == 0 {
+	defer resp.Body.Close()
+	if resp.URL == "" || resp.ContentType == "" || resp.Elapsed == 0 {
 		t.Fatal("unexpected empty values")
 	}
 }
@@ -37,8 +49,275 @@ func TestRejectNonHTML(t *testing.T) {
 	defer ts.Close()
 
 	client := NewHTTPClient(5*time.Second, 2*time.Second, 1024)
-	_, _, _, _, err := client.Fetch(context.Background(), ts.URL)
+	_, err := client.Fetch(context.Background(), ts.URL)
 	if err == nil {
 		t.Fatal("expected error for non-html")
 	}
+	if fetcherr.CodeOf(err) != fetcherr.CodeNonHTML {
+		t.Fatalf("want non_html code, got %v", fetcherr.CodeOf(err))
+	}
+}
+
+func TestRobotsDisallowed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(5*time.Second, 2*time.Second, 1024)
+	_, err := client.Fetch(context.Background(), ts.URL+"/private/page")
+	var fe *fetcherr.Error
+	if !errors.As(err, &fe) || fe.Code != fetcherr.CodeRobotsDenied || fe.Retryable {
+		t.Fatalf("want robots_denied error, got %v", err)
+	}
+	resp, err := client.Fetch(context.Background(), ts.URL+"/public")
+	if err != nil {
+		t.Fatalf("public fetch err: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(404)
+			return
+		}
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(503)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	client := NewHTTPClient(5*time.Second, 2*time.Second, 1024, WithRetry(p))
+	resp, attempts, err := client.FetchWithRetry(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("fetch err: %v", err)
+	}
+	resp.Body.Close()
+	if len(attempts) != 3 || len(AttemptErrors(attempts)) != 2 {
+		t.Fatalf("unexpected attempts: %#v", attempts)
+	}
+
+	calls = -10 // keep failing
+	_, attempts, err = client.FetchWithRetry(context.Background(), ts.URL+"/missing")
+	var fe *fetcherr.Error
+	if !errors.As(err, &fe) || fe.HTTPStatus != 503 || !fe.Retryable || len(attempts) != 3 {
+		t.Fatalf("want 3 failed attempts with 503, got %v (%d attempts)", err, len(attempts))
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=ISO-8859-1")
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+			w.Write([]byte("<html></html>"))
+		}
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(5*time.Second, 2*time.Second, 1024, WithRobots(false))
+	resp, err := client.Fetch(context.Background(), ts.URL+"/old")
+	if err != nil {
+		t.Fatalf("fetch err: %v", err)
+	}
+	defer resp.Body.Close()
+	if len(resp.Redirects) != 2 || resp.Redirects[0].StatusCode != 301 || resp.Redirects[1].StatusCode != 302 {
+		t.Fatalf("unexpected redirects: %#v", resp.Redirects)
+	}
+	if resp.URL != ts.URL+"/final" || resp.StatusCode != 200 || resp.Charset != "ISO-8859-1" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.RemoteIP != "127.0.0.1" || resp.Header.Get("Last-Modified") == "" {
+		t.Fatalf("missing remote ip or headers: %+v", resp)
+	}
+}
+
+func TestContentDecoding(t *testing.T) {
+	const page = "<html><title>encoded</title></html>"
+	gz := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	deflate := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	br := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w := brotli.NewWriter(&buf)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	zst := func(b []byte) []byte {
+		enc, _ := zstd.NewWriter(nil)
+		return enc.EncodeAll(b, nil)
+	}
+
+	cases := map[string][]byte{
+		"gzip":          gz([]byte(page)),
+		"deflate":       deflate([]byte(page)),
+		"br":            br([]byte(page)),
+		"zstd":          zst([]byte(page)),
+		"deflate, gzip": gz(deflate([]byte(page))),
+	}
+	for enc, data := range cases {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", enc)
+			w.Write(data)
+		}))
+		client := NewHTTPClient(5*time.Second, 2*time.Second, 1024, WithRobots(false))
+		resp, err := client.Fetch(context.Background(), ts.URL)
+		if err != nil {
+			t.Fatalf("%s: fetch err: %v", enc, err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ts.Close()
+		if err != nil || string(got) != page {
+			t.Fatalf("%s: got %q, %v", enc, got, err)
+		}
+	}
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(bytes.Repeat([]byte("a"), 8<<20))
+	w.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(5*time.Second, 2*time.Second, 16<<20, WithRobots(false))
+	resp, err := client.Fetch(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("fetch err: %v", err)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	if fetcherr.CodeOf(err) != fetcherr.CodeTooLarge {
+		t.Fatalf("want too_large, got %v", err)
+	}
+}
+
+func TestOversize(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 4096)
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write(big)
+	zw.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Content-Length", strconv.Itoa(zipped.Len()))
+			w.Write(zipped.Bytes())
+			return
+		}
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush() // force chunked encoding, no Content-Length
+		} else {
+			w.Header().Set("Content-Length", "4096")
+		}
+		w.Write(big)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(5*time.Second, 2*time.Second, 1024, WithRobots(false))
+	resp, err := client.Fetch(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("fetch err: %v", err)
+	}
+	got, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(got) != 1024 || !resp.Truncated() || resp.DecodedLength() != 4096 {
+		t.Fatalf("want 1024 truncated bytes, got %d (truncated=%v, err=%v)", len(got), resp.Truncated(), err)
+	}
+	resp, err = client.Fetch(context.Background(), ts.URL+"/gzip")
+	if err != nil {
+		t.Fatalf("fetch err: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !resp.Truncated() || resp.ContentLength <= 0 || resp.DecodedLength() != -1 {
+		t.Fatalf("want unknown decoded length for a compressed body, got %d (content-length %d)", resp.DecodedLength(), resp.ContentLength)
+	}
+
+	strict := client.With(WithOversize(OversizeFail))
+	if _, err := strict.Fetch(context.Background(), ts.URL); fetcherr.CodeOf(err) != fetcherr.CodeTooLarge {
+		t.Fatalf("want too_large from Content-Length, got %v", err)
+	}
+	resp, err = strict.Fetch(context.Background(), ts.URL+"/chunked")
+	if err != nil {
+		t.Fatalf("fetch err: %v", err)
+	}
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if fetcherr.CodeOf(err) != fetcherr.CodeTooLarge {
+		t.Fatalf("want too_large while reading, got %v", err)
+	}
+}
+
+func TestNetPolicy(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hop" {
+			http.Redirect(w, r, strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)+"/", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	blocked := NewHTTPClient(5*time.Second, 2*time.Second, 1024, WithNetPolicy(NetPolicy{BlockPrivate: true}))
+	for _, u := range []string{ts.URL, strings.Replace(ts.URL, "127.0.0.1", "localhost", 1), "http://169.254.169.254/latest/meta-data/"} {
+		if _, err := blocked.Fetch(context.Background(), u); fetcherr.CodeOf(err) != fetcherr.CodePolicy {
+			t.Fatalf("%s: want policy_denied, got %v", u, err)
+		}
+	}
+
+	allowed := NewHTTPClient(5*time.Second, 2*time.Second, 1024, WithNetPolicy(NetPolicy{
+		BlockPrivate: true,
+		AllowHosts:   []string{"127.0.0.0/8"},
+		DenyHosts:    []string{"localhost"},
+	}))
+	resp, err := allowed.Fetch(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("allow-listed fetch err: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := allowed.Fetch(context.Background(), ts.URL+"/hop"); fetcherr.CodeOf(err) != fetcherr.CodePolicy {
+		t.Fatalf("want policy_denied on redirect, got %v", err)
+	}
 }
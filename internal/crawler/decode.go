@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"brightedge-go-crawler/internal/fetcherr"
+)
+
+// Decoder wraps a stream encoded with one Content-Encoding in a decoding reader.
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// defaultDecoders lists the built-in encodings in Accept-Encoding preference order.
+var defaultDecoders = []struct {
+	name string
+	dec  Decoder
+}{
+	{"gzip", decodeGzip},
+	{"deflate", decodeDeflate},
+	{"br", decodeBrotli},
+	{"zstd", decodeZstd},
+}
+
+// WithDecoder registers (or replaces) the decoder for a Content-Encoding token.
+// Registered encodings are advertised in Accept-Encoding.
+func WithDecoder(name string, d Decoder) Option {
+	return func(h *HTTPClient) { h.setDecoder(name, d) }
+}
+
+// WithMaxDecompressionRatio caps decoded bytes per encoded byte; 0 disables the check.
+func WithMaxDecompressionRatio(ratio float64) Option {
+	return func(h *HTTPClient) { h.maxRatio = ratio }
+}
+
+func (h *HTTPClient) setDecoder(name string, d Decoder) {
+	name = strings.ToLower(name)
+	if _, ok := h.decoders[name]; !ok {
+		h.encodings = append(h.encodings, name)
+	}
+	h.decoders[name] = d
+}
+
+func (h *HTTPClient) acceptEncoding() string {
+	return strings.Join(h.encodings, ", ")
+}
+
+// ratioGrace is how many decoded bytes are allowed before the ratio check kicks in.
+const ratioGrace = 1 << 20
+
+// decode unwraps every encoding listed in contentEncoding, last applied first.
+// The returned closers must be closed after the body is consumed.
+func (h *HTTPClient) decode(r io.Reader, contentEncoding string) (io.Reader, []io.Closer, error) {
+	if contentEncoding == "" {
+		return r, nil, nil
+	}
+	encoded := &countingReader{r: r}
+	r = encoded
+	var closers []io.Closer
+	tokens := strings.Split(contentEncoding, ",")
+	for i := len(tokens) - 1; i >= 0; i-- {
+		name := strings.ToLower(strings.TrimSpace(tokens[i]))
+		if name == "" || name == "identity" {
+			continue
+		}
+		if name == "x-gzip" {
+			name = "gzip"
+		}
+		d, ok := h.decoders[name]
+		if !ok {
+			closeAll(closers)
+			return nil, nil, fetcherr.Errorf(fetcherr.CodeDecode, "unsupported content-encoding %q", name)
+		}
+		rc, err := d(r)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, fetcherr.New(fetcherr.CodeDecode, err)
+		}
+		closers = append([]io.Closer{rc}, closers...)
+		r = rc
+	}
+	if h.maxRatio > 0 {
+		r = &ratioReader{r: r, encoded: encoded, max: h.maxRatio}
+	}
+	return r, closers, nil
+}
+
+func closeAll(cs []io.Closer) {
+	for _, c := range cs {
+		c.Close()
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader fails once decoded output grows past max times the encoded
+// input, which guards against decompression bombs.
+type ratioReader struct {
+	r       io.Reader
+	encoded *countingReader
+	n       int64
+	max     float64
+}
+
+func (rr *ratioReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.n += int64(n)
+	if rr.n > ratioGrace && float64(rr.n) > rr.max*float64(max(rr.encoded.n, 1)) {
+		return n, fetcherr.Errorf(fetcherr.CodeTooLarge, "decompression ratio exceeds %.0f:1", rr.max)
+	}
+	return n, err
+}
+
+func decodeGzip(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+
+// decodeDeflate accepts both zlib-wrapped (RFC 1950, what the spec says)
+// and raw deflate streams (what many servers actually send).
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	hdr, err := br.Peek(2)
+	if err == nil && hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+func decodeBrotli(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
+
+func decodeZstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"brightedge-go-crawler/internal/fetcherr"
+)
+
+// NetPolicy restricts the destinations the client may reach. Host entries
+// are hostnames ("example.com", or ".example.com" for any subdomain), IPs or
+// CIDRs. Deny entries win over allow entries, and an allowed host or range
+// bypasses BlockPrivate.
+type NetPolicy struct {
+	BlockPrivate bool // loopback, private, link-local (incl. cloud metadata), CGNAT and other non-public ranges
+	AllowHosts   []string
+	DenyHosts    []string
+}
+
+// WithNetPolicy enforces p on every request, redirect hop and dialed IP.
+// It must be passed to NewHTTPClient; it has no effect on With. Environment
+// proxies are disabled under a policy since the dial target would be the proxy.
+func WithNetPolicy(p NetPolicy) Option {
+	return func(h *HTTPClient) { h.policy = compilePolicy(p) }
+}
+
+type netPolicy struct {
+	blockPrivate bool
+	allowNames   []string
+	allowNets    []netip.Prefix
+	denyNames    []string
+	denyNets     []netip.Prefix
+}
+
+func compilePolicy(p NetPolicy) *netPolicy {
+	np := &netPolicy{blockPrivate: p.BlockPrivate}
+	np.allowNames, np.allowNets = splitHosts(p.AllowHosts)
+	np.denyNames, np.denyNets = splitHosts(p.DenyHosts)
+	return np
+}
+
+func splitHosts(entries []string) (names []string, nets []netip.Prefix) {
+	for _, e := range entries {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if pfx, err := netip.ParsePrefix(e); err == nil {
+			nets = append(nets, pfx.Masked())
+		} else if addr, err := netip.ParseAddr(e); err == nil {
+			nets = append(nets, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		} else {
+			names = append(names, e)
+		}
+	}
+	return names, nets
+}
+
+func matchName(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if host == p || (strings.HasPrefix(p, ".") && (strings.HasSuffix(host, p) || host == p[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNet(nets []netip.Prefix, ip netip.Addr) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonPublic lists ranges not covered by the netip.Addr predicates.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT, also Alibaba Cloud metadata
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, checked again on the embedded IPv4
+}
+
+func isPrivate(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	return matchNet(nonPublic, ip)
+}
+
+func blocked(format string, args ...any) *fetcherr.Error {
+	return fetcherr.Errorf(fetcherr.CodePolicy, format, args...)
+}
+
+// checkHost runs before a request or redirect is sent. hostOK reports
+// whether the name itself is allow-listed, which skips the IP checks.
+func (p *netPolicy) checkHost(host string) (hostOK bool, err error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchName(p.denyNames, host) {
+		return false, blocked("host %s is denied by policy", host)
+	}
+	if matchName(p.allowNames, host) {
+		return true, nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return false, p.checkIP(ip)
+	}
+	return false, nil
+}
+
+// checkIP runs on every address actually dialed, after DNS resolution.
+func (p *netPolicy) checkIP(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if matchNet(p.denyNets, ip) {
+		return blocked("address %s is denied by policy", ip)
+	}
+	if matchNet(p.allowNets, ip) {
+		return nil
+	}
+	if p.blockPrivate && isPrivate(ip) {
+		return blocked("address %s is not public", ip)
+	}
+	return nil
+}
+
+// dialContext wraps d so that every resolved address is checked before the
+// connection is made, which also covers DNS rebinding and redirects.
+func (p *netPolicy) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	checked := *d
+	checked.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return blocked("unparseable dial address %s", address)
+		}
+		return p.checkIP(ip)
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		hostOK, err := p.checkHost(host)
+		if err != nil {
+			return nil, err
+		}
+		if hostOK {
+			return d.DialContext(ctx, network, addr)
+		}
+		return checked.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"brightedge-go-crawler/internal/fetcherr"
+)
+
+// Post sends body to rawURL and returns the response status. It is meant
+// for callbacks: the network policy applies as for Fetch, but robots.txt
+// does not, and redirects are not followed since a redirected POST would
+// lose its body.
+func (h *HTTPClient) Post(ctx context.Context, rawURL string, header http.Header, body []byte) (int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return 0, fetcherr.Errorf(fetcherr.CodeInvalidURL, "invalid url")
+	}
+	if h.policy != nil {
+		if _, err := h.policy.checkHost(u.Hostname()); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", h.userAgent)
+
+	c := *h.client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, fetcherr.Classify(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
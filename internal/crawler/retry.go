@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"brightedge-go-crawler/internal/fetcherr"
+)
+
+// RetryPolicy controls how FetchWithRetry retries transient failures.
+type RetryPolicy struct {
+	MaxAttempts   int           // total tries including the first; <= 1 disables retries
+	BaseDelay     time.Duration // delay before the second try, doubled on each retry
+	MaxDelay      time.Duration // cap for the computed backoff
+	Jitter        float64       // fraction of each delay that is randomized, 0..1
+	MaxRetryAfter time.Duration // give up if the server asks us to wait longer than this
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:   3,
+		BaseDelay:     500 * time.Millisecond,
+		MaxDelay:      10 * time.Second,
+		Jitter:        0.5,
+		MaxRetryAfter: 60 * time.Second,
+	}
+}
+
+// WithRetry sets the policy used by FetchWithRetry.
+func WithRetry(p RetryPolicy) Option {
+	return func(h *HTTPClient) { h.retry = p }
+}
+
+// Attempt records the outcome of one try made by FetchWithRetry.
+type Attempt struct {
+	Err  error
+	Wait time.Duration // time slept before the next try
+}
+
+// FetchWithRetry calls Fetch until it succeeds, fails with a non-retryable
+// error, or the policy's attempts are exhausted. Every failed try is
+// returned in attempts, followed by the successful one if any.
+func (h *HTTPClient) FetchWithRetry(ctx context.Context, rawURL string) (*Response, []Attempt, error) {
+	var attempts []Attempt
+	for n := 1; ; n++ {
+		resp, err := h.Fetch(ctx, rawURL)
+		attempts = append(attempts, Attempt{Err: err})
+		if err == nil {
+			return resp, attempts, nil
+		}
+		fe := fetcherr.Classify(err)
+		if n >= h.retry.MaxAttempts || ctx.Err() != nil || !fe.Retryable {
+			return nil, attempts, err
+		}
+		wait := h.retry.backoff(n)
+		if fe.RetryAfter > 0 {
+			if h.retry.MaxRetryAfter > 0 && fe.RetryAfter > h.retry.MaxRetryAfter {
+				return nil, attempts, err
+			}
+			wait = fe.RetryAfter
+		}
+		attempts[len(attempts)-1].Wait = wait
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, attempts, err
+		}
+	}
+}
+
+// AttemptErrors returns the error text of every failed attempt, in order.
+func AttemptErrors(attempts []Attempt) []string {
+	var out []string
+	for _, a := range attempts {
+		if a.Err != nil {
+			out = append(out, a.Err.Error())
+		}
+	}
+	return out
+}
+
+// backoff returns the delay after the n-th failed try.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		j := min(p.Jitter, 1)
+		d = time.Duration(float64(d) * (1 - j + j*rand.Float64()))
+	}
+	return d
+}
+
+// parseRetryAfter accepts both delta-seconds and HTTP-date forms.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+// Package dedup provides seen-sets for skipping repeated input URLs.
+package dedup
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+// Set remembers keys. Add reports whether key was new, i.e. not seen before;
+// Has reports whether it was seen without adding it. Sets are not safe for
+// concurrent use.
+type Set interface {
+	Add(key string) bool
+	Has(key string) bool
+}
+
+// Exact is a map-backed Set with no false positives.
+type Exact map[string]struct{}
+
+func NewExact() Exact { return Exact{} }
+
+func (s Exact) Add(key string) bool {
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+func (s Exact) Has(key string) bool {
+	_, ok := s[key]
+	return ok
+}
+
+// Off is a Set that never reports a duplicate.
+type Off struct{}
+
+func (Off) Add(string) bool { return true }
+
+func (Off) Has(string) bool { return false }
+
+// Bloom is a fixed-size Set for very large inputs. Memory does not grow with
+// the number of keys, but a new key is wrongly reported as seen with
+// probability up to the configured rate once capacity keys were added.
+type Bloom struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    int    // hash functions
+	s1   maphash.Seed
+	s2   maphash.Seed
+}
+
+// NewBloom sizes a filter for capacity keys at false-positive rate fp.
+func NewBloom(capacity int, fp float64) *Bloom {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.001
+	}
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := max(int(math.Round(float64(m)/float64(capacity)*math.Ln2)), 1)
+	return &Bloom{bits: make([]uint64, (m+63)/64), m: m, k: k, s1: maphash.MakeSeed(), s2: maphash.MakeSeed()}
+}
+
+func (b *Bloom) Add(key string) bool {
+	added := false
+	b.each(key, func(w int, mask uint64) {
+		if b.bits[w]&mask == 0 {
+			b.bits[w] |= mask
+			added = true
+		}
+	})
+	return added
+}
+
+func (b *Bloom) Has(key string) bool {
+	has := true
+	b.each(key, func(w int, mask uint64) {
+		has = has && b.bits[w]&mask != 0
+	})
+	return has
+}
+
+// each calls fn with the word and bit mask of each of key's k bits.
+func (b *Bloom) each(key string, fn func(w int, mask uint64)) {
+	h1, h2 := maphash.String(b.s1, key), maphash.String(b.s2, key)|1
+	for i := 0; i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		fn(int(bit/64), uint64(1)<<(bit%64))
+	}
+}
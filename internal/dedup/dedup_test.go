@@ -0,0 +1,35 @@
+package dedup
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestExact(t *testing.T) {
+	s := NewExact()
+	if !s.Add("a") || s.Add("a") || !s.Has("a") || s.Has("b") || !s.Add("b") {
+		t.Fatal("exact set misreported membership")
+	}
+}
+
+func TestBloom(t *testing.T) {
+	const n = 20000
+	b := NewBloom(n, 0.01)
+	for i := 0; i < n; i++ {
+		b.Add("https://example.com/" + strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		if b.Add("https://example.com/" + strconv.Itoa(i)) {
+			t.Fatalf("key %d forgotten", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < n; i++ {
+		if b.Has("https://example.org/" + strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / n; rate > 0.02 {
+		t.Fatalf("false-positive rate %.4f, want about 0.01", rate)
+	}
+}
@@ -0,0 +1,160 @@
+package fetcherr
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Code groups failures for reporting. Codes are stable strings emitted as errorCode.
+type Code string
+
+const (
+	CodeInvalidURL     Code = "invalid_url"
+	CodeDNS            Code = "dns"
+	CodeConnectTimeout Code = "connect_timeout"
+	CodeConnect        Code = "connect"
+	CodeTimeout        Code = "timeout"
+	CodeTLS            Code = "tls"
+	CodeNetwork        Code = "network"
+	CodeHTTPStatus     Code = "http_status"
+	CodeRobotsDenied   Code = "robots_denied"
+	CodePolicy         Code = "policy_denied"
+	CodeNonHTML        Code = "non_html"
+	CodeTooLarge       Code = "too_large"
+	CodeDecode         Code = "decode"
+	CodeCanceled       Code = "canceled"
+	CodeUnknown        Code = "unknown"
+)
+
+// Error is the single error type returned by the crawler and parser.
+type Error struct {
+	Code       Code
+	URL        string
+	HTTPStatus int
+	Retryable  bool
+	RetryAfter time.Duration // server-requested delay, only set for 429/503
+	Err        error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err != nil:
+		return e.Err.Error()
+	case e.Code == CodeHTTPStatus:
+		return fmt.Sprintf("http status %d", e.HTTPStatus)
+	case e.Code == CodeRobotsDenied:
+		return "disallowed by robots.txt: " + e.URL
+	default:
+		return string(e.Code)
+	}
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New wraps err with code. Retryable is derived from the code.
+func New(code Code, err error) *Error {
+	return &Error{Code: code, Retryable: retryableCode(code), Err: err}
+}
+
+// Errorf is New with a formatted message.
+func Errorf(code Code, format string, args ...any) *Error {
+	return New(code, fmt.Errorf(format, args...))
+}
+
+// Status builds an http_status error. 408, 425, 429 and 5xx gateway/availability errors are retryable.
+func Status(status int, retryAfter time.Duration) *Error {
+	e := &Error{Code: CodeHTTPStatus, HTTPStatus: status, RetryAfter: retryAfter}
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		e.Retryable = true
+	}
+	return e
+}
+
+// Robots builds a robots_denied error for url.
+func Robots(url string) *Error {
+	return &Error{Code: CodeRobotsDenied, URL: url}
+}
+
+func retryableCode(c Code) bool {
+	switch c {
+	case CodeConnectTimeout, CodeConnect, CodeTimeout, CodeNetwork:
+		return true
+	}
+	return false
+}
+
+// Classify returns err as an *Error, inferring the code from the
+// underlying network, TLS or context error when err is not one already.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var fe *Error
+	if errors.As(err, &fe) {
+		return fe
+	}
+	code := classify(err)
+	e := New(code, err)
+	var dnsErr *net.DNSError
+	if code == CodeDNS && errors.As(err, &dnsErr) {
+		e.Retryable = dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	return e
+}
+
+func classify(err error) Code {
+	if errors.Is(err, context.Canceled) {
+		return CodeCanceled
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return CodeDNS
+	}
+	var (
+		recErr   tls.RecordHeaderError
+		alertErr tls.AlertError
+		certErr  *tls.CertificateVerificationError
+		authErr  x509.UnknownAuthorityError
+		hostErr  x509.HostnameError
+		invErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &recErr) || errors.As(err, &alertErr) || errors.As(err, &certErr) ||
+		errors.As(err, &authErr) || errors.As(err, &hostErr) || errors.As(err, &invErr) {
+		return CodeTLS
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		if opErr.Timeout() {
+			return CodeConnectTimeout
+		}
+		return CodeConnect
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return CodeTimeout
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return CodeNetwork
+	}
+	return CodeUnknown
+}
+
+// CodeOf is shorthand for Classify(err).Code; it returns "" for a nil error.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	return Classify(err).Code
+}
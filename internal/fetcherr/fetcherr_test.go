@@ -0,0 +1,31 @@
+package fetcherr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err       error
+		code      Code
+		retryable bool
+	}{
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, CodeDNS, false},
+		{&net.DNSError{Err: "timeout", IsTimeout: true}, CodeDNS, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, CodeConnect, true},
+		{fmt.Errorf("get: %w", context.Canceled), CodeCanceled, false},
+		{Status(404, 0), CodeHTTPStatus, false},
+		{Status(503, 0), CodeHTTPStatus, true},
+		{Robots("http://x/"), CodeRobotsDenied, false},
+	}
+	for _, c := range cases {
+		fe := Classify(c.err)
+		if fe.Code != c.code || fe.Retryable != c.retryable {
+			t.Errorf("Classify(%v) = %s/%v, want %s/%v", c.err, fe.Code, fe.Retryable, c.code, c.retryable)
+		}
+	}
+}
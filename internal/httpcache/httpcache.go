@@ -0,0 +1,132 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"brightedge-go-crawler/internal/models"
+	"brightedge-go-crawler/internal/urlnorm"
+)
+
+// Entry is what the cache keeps per URL: the validators from the last 200
+// response and the result parsed from it, with its full link list.
+type Entry struct {
+	URL          string             `json:"url"`
+	ETag         string             `json:"etag,omitempty"`
+	LastModified string             `json:"lastModified,omitempty"`
+	StoredAt     time.Time          `json:"storedAt"`
+	Result       models.CrawlResult `json:"result"`
+}
+
+// Cache is an on-disk validator cache, one JSON file per URL. A nil *Cache
+// is valid and behaves as an always-empty cache.
+type Cache struct {
+	dir  string
+	norm *urlnorm.Normalizer
+}
+
+// Open creates dir if needed and returns a cache rooted there. URLs are
+// keyed by norm, which should be the crawl's normalizer; nil uses
+// urlnorm.Default.
+func Open(dir string, norm *urlnorm.Normalizer) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if norm == nil {
+		norm = urlnorm.Default
+	}
+	return &Cache{dir: dir, norm: norm}, nil
+}
+
+// Key returns the cache key for rawURL, its normalized form.
+func (c *Cache) Key(rawURL string) string {
+	return c.norm.Key(rawURL)
+}
+
+func (c *Cache) path(rawURL string) string {
+	sum := sha256.Sum256([]byte(c.Key(rawURL)))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name+".json")
+}
+
+// Get returns the entry stored for rawURL.
+func (c *Cache) Get(rawURL string) (*Entry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(rawURL))
+	if err != nil {
+		return nil, false
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+// Validators returns the ETag and Last-Modified stored for rawURL.
+func (c *Cache) Validators(rawURL string) (etag, lastModified string) {
+	e, ok := c.Get(rawURL)
+	if !ok {
+		return "", ""
+	}
+	return e.ETag, e.LastModified
+}
+
+// Store saves result under rawURL. Responses without validators are not
+// cached since they can never be revalidated, and truncated results are not
+// either since a 304 would replay them as the whole page.
+func (c *Cache) Store(rawURL, etag, lastModified string, result models.CrawlResult) error {
+	if c == nil || (etag == "" && lastModified == "") || result.Truncated {
+		return nil
+	}
+	data, err := json.Marshal(Entry{
+		URL:          c.Key(rawURL),
+		ETag:         etag,
+		LastModified: lastModified,
+		StoredAt:     time.Now().UTC(),
+		Result:       result,
+	})
+	if err != nil {
+		return err
+	}
+	p := c.path(rawURL)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// ErrNoCachedResult is returned by Revalidated when a 304 arrives for a URL
+// that has nothing cached.
+var ErrNoCachedResult = errors.New("304 not modified but no cached result")
+
+// Revalidated returns the cached result for rawURL marked as not modified.
+func (c *Cache) Revalidated(rawURL string) (models.CrawlResult, error) {
+	e, ok := c.Get(rawURL)
+	if !ok {
+		return models.CrawlResult{}, ErrNoCachedResult
+	}
+	r := e.Result
+	r.NotModified = true
+	return r, nil
+}
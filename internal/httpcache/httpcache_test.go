@@ -0,0 +1,64 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"brightedge-go-crawler/internal/crawler"
+	"brightedge-go-crawler/internal/models"
+	"brightedge-go-crawler/internal/urlnorm"
+)
+
+func TestConditionalGet(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	cache, err := Open(t.TempDir(), urlnorm.New("ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := crawler.NewHTTPClient(5*time.Second, 2*time.Second, 1024, crawler.WithRobots(false), crawler.WithValidators(cache))
+
+	resp, err := client.Fetch(context.Background(), ts.URL+"/page#frag")
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.NotModified {
+		t.Fatal("first fetch should not be a 304")
+	}
+	stored := models.CrawlResult{SourceURL: resp.URL, Meta: models.Meta{Title: "x"}}
+	if err := cache.Store(ts.URL+"/page", resp.Header.Get("ETag"), "", stored); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = client.Fetch(context.Background(), ts.URL+"/page?ref=feed")
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if !resp.NotModified {
+		t.Fatal("want 304 on second fetch")
+	}
+	got, err := cache.Revalidated(ts.URL + "/page")
+	if err != nil || !got.NotModified || got.Meta.Title != "x" {
+		t.Fatalf("unexpected cached result: %+v, %v", got, err)
+	}
+
+	if err := cache.Store(ts.URL+"/cut", `"v1"`, "", models.CrawlResult{Truncated: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(ts.URL + "/cut"); ok {
+		t.Fatal("truncated result was cached")
+	}
+}
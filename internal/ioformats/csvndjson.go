@@ -3,105 +3,266 @@ package ioformats
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// ReadURLs reads URLs from a CSV (expects header with "url") or NDJSON file.
-// If ext cannot be determined, tries CSV first then NDJSON.
-func ReadURLs(path string) ([]string, error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
+// Format is the layout of a URL input file.
+type Format int
+
+const (
+	FormatAuto   Format = iota // CSV if the first line has a "url" column, else NDJSON
+	FormatCSV                  // header row with a "url" column
+	FormatNDJSON               // one URL or {"url": "..."} object per line
+)
+
+// FormatOf picks the format from a file name's extension.
+func FormatOf(name string) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
 	case ".csv":
-		return readCSV(path)
+		return FormatCSV
 	case ".ndjson", ".jsonl":
-		return readNDJSON(path)
-	default:
-		// try csv then ndjson
-		if urls, err := readCSV(path); err == nil && len(urls) > 0 {
-			return urls, nil
-		}
-		return readNDJSON(path)
+		return FormatNDJSON
 	}
+	return FormatAuto
 }
 
-func readCSV(path string) ([]string, error) {
-	f, err := os.Open(path)
+// LineError is an input line that holds no usable URL. Reading can go on
+// past it.
+type LineError struct {
+	Line   int
+	Text   string
+	Reason string
+}
+
+func (e *LineError) Error() string {
+	text := e.Text
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+	return fmt.Sprintf("line %d: %s: %q", e.Line, e.Reason, text)
+}
+
+// Reader reads URLs one at a time, so inputs of any size are read in
+// constant memory.
+type Reader struct {
+	br     *bufio.Reader
+	csv    *csv.Reader
+	col    int
+	line   int
+	closer io.Closer
+}
+
+// NewReader reads URLs in format f from r.
+func NewReader(r io.Reader, f Format) (*Reader, error) {
+	rd := &Reader{br: bufio.NewReaderSize(r, 64<<10)}
+	if f == FormatAuto {
+		f = rd.sniff()
+	}
+	if f != FormatCSV {
+		return rd, nil
+	}
+
+	rd.csv = csv.NewReader(rd.br)
+	rd.csv.FieldsPerRecord = -1
+	header, err := rd.csv.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty csv")
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	rd.col = urlColumn(header)
+	if rd.col == -1 {
+		return nil, errors.New("csv must contain a 'url' header column")
+	}
+	rd.csv.ReuseRecord = true
+	return rd, nil
+}
 
-	r := csv.NewReader(f)
-	r.FieldsPerRecord = -1
-	rows, err := r.ReadAll()
+// Open reads URLs from the file at path, in the format its extension names.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	if len(rows) == 0 {
-		return nil, errors.New("empty csv")
+	rd, err := NewReader(f, FormatOf(path))
+	if err != nil {
+		f.Close()
+		return nil, err
 	}
-	// find "url" column
-	col := -1
-	for i, h := range rows[0] {
-		if strings.EqualFold(strings.TrimSpace(h), "url") {
-			col = i
-			break
+	rd.closer = f
+	return rd, nil
+}
+
+// Close closes the file opened by Open.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Next returns the next URL. Blank lines are skipped; a line without a
+// usable URL returns a *LineError, after which Next may be called again.
+// Next returns io.EOF at the end of the input.
+func (r *Reader) Next() (string, error) {
+	for {
+		var u string
+		var line int
+		var err error
+		if r.csv != nil {
+			u, line, err = r.nextCSV()
+		} else {
+			u, line, err = r.nextNDJSON()
 		}
+		if err != nil {
+			return "", err
+		}
+		if u == "" {
+			continue
+		}
+		if pu, perr := url.Parse(u); perr != nil || (pu.Scheme != "http" && pu.Scheme != "https") || pu.Host == "" {
+			return "", &LineError{Line: line, Text: u, Reason: "not an http(s) URL"}
+		}
+		return u, nil
 	}
-	if col == -1 {
-		return nil, errors.New("csv must contain a 'url' header column")
+}
+
+func (r *Reader) nextCSV() (string, int, error) {
+	rec, err := r.csv.Read()
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return "", 0, &LineError{Line: pe.Line, Reason: pe.Err.Error()}
 	}
-	var out []string
-	for _, row := range rows[1:] {
-		if col < len(row) {
-			u := strings.TrimSpace(row[col])
-			if u != "" {
-				out = append(out, u)
-			}
+	if err != nil {
+		return "", 0, err
+	}
+	line, _ := r.csv.FieldPos(0)
+	if r.col >= len(rec) {
+		return "", 0, &LineError{Line: line, Text: strings.Join(rec, ","), Reason: "missing url column"}
+	}
+	return strings.TrimSpace(rec[r.col]), line, nil
+}
+
+func (r *Reader) nextNDJSON() (string, int, error) {
+	s, err := r.br.ReadString('\n')
+	if err == io.EOF && s == "" {
+		return "", 0, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	r.line++
+	line := strings.TrimSpace(s)
+	// allow raw string or {"url": "..."}
+	if !strings.HasPrefix(line, "{") {
+		return line, r.line, nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		return "", 0, &LineError{Line: r.line, Text: line, Reason: "invalid JSON"}
+	}
+	u, ok := obj["url"].(string)
+	if !ok || strings.TrimSpace(u) == "" {
+		return "", 0, &LineError{Line: r.line, Text: line, Reason: `missing "url" string`}
+	}
+	return strings.TrimSpace(u), r.line, nil
+}
+
+// sniff reports FormatCSV if the first line parses as a CSV header with a
+// "url" column.
+func (r *Reader) sniff() Format {
+	head, _ := r.br.Peek(r.br.Size())
+	if i := strings.IndexByte(string(head), '\n'); i >= 0 {
+		head = head[:i]
+	}
+	first := strings.TrimSpace(string(head))
+	if first == "" || strings.HasPrefix(first, "{") {
+		return FormatNDJSON
+	}
+	header, err := csv.NewReader(strings.NewReader(first)).Read()
+	if err == nil && urlColumn(header) >= 0 {
+		return FormatCSV
+	}
+	return FormatNDJSON
+}
+
+func urlColumn(header []string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), "url") {
+			return i
 		}
 	}
-	return out, nil
+	return -1
 }
 
-func readNDJSON(path string) ([]string, error) {
-	f, err := os.Open(path)
+// Stream reads r in a goroutine and sends each URL on the returned channel.
+// The channel is unbuffered, so reading never runs more than one URL ahead
+// of the consumer. Bad lines go to bad, if non-nil, and are skipped. Once
+// the channel is closed, the error channel yields the read error, ctx's
+// error, or nil at the end of input.
+func Stream(ctx context.Context, r *Reader, bad func(*LineError)) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		errc <- func() error {
+			for {
+				u, err := r.Next()
+				var le *LineError
+				switch {
+				case errors.As(err, &le):
+					if bad != nil {
+						bad(le)
+					}
+					continue
+				case err == io.EOF:
+					return nil
+				case err != nil:
+					return err
+				}
+				select {
+				case out <- u:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}()
+	}()
+	return out, errc
+}
+
+// ReadURLs reads every URL from a CSV (expects header with "url") or NDJSON
+// file into memory. It fails on the first bad line; use Open and Stream for
+// large inputs.
+func ReadURLs(path string) ([]string, error) {
+	r, err := Open(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	defer r.Close()
 	var out []string
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
-		if line == "" {
-			continue
+	for {
+		u, err := r.Next()
+		if err == io.EOF {
+			break
 		}
-		// allow raw string or {"url": "..."}
-		if strings.HasPrefix(line, "{") {
-			var obj map[string]any
-			if err := json.Unmarshal([]byte(line), &obj); err == nil {
-				if v, ok := obj["url"]; ok {
-					if s, ok := v.(string); ok && s != "" {
-						out = append(out, s)
-						continue
-					}
-				}
-			}
+		if err != nil {
+			return nil, err
 		}
-		// fallback: treat whole line as url
-		out = append(out, line)
-	}
-	if err := sc.Err(); err != nil {
-		return nil, err
+		out = append(out, u)
 	}
 	if len(out) == 0 {
-		return nil, errors.New("no urls found in ndjson")
+		return nil, errors.New("no urls found")
 	}
 	return out, nil
 }
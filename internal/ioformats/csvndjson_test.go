@@ -0,0 +1,78 @@
+package ioformats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	cases := map[string]struct {
+		in   string
+		want []string
+		bad  []int // line numbers
+	}{
+		"ndjson": {
+			in:   "https://a.example/\n\n{\"url\":\"https://b.example/\"}\n{\"id\":1}\nnot a url\n{broken\nhttps://c.example/",
+			want: []string{"https://a.example/", "https://b.example/", "https://c.example/"},
+			bad:  []int{4, 5, 6},
+		},
+		"csv": {
+			in:   "id,url\n1,https://a.example/\n2\n3,ftp://b.example/\n4, https://c.example/ \n",
+			want: []string{"https://a.example/", "https://c.example/"},
+			bad:  []int{3, 4},
+		},
+	}
+	for name, c := range cases {
+		r, err := NewReader(strings.NewReader(c.in), FormatAuto)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var bad []int
+		urls, errc := Stream(context.Background(), r, func(le *LineError) { bad = append(bad, le.Line) })
+		var got []string
+		for u := range urls {
+			got = append(got, u)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if strings.Join(got, " ") != strings.Join(c.want, " ") {
+			t.Errorf("%s: got urls %v, want %v", name, got, c.want)
+		}
+		if len(bad) != len(c.bad) {
+			t.Fatalf("%s: got bad lines %v, want %v", name, bad, c.bad)
+		}
+		for i := range bad {
+			if bad[i] != c.bad[i] {
+				t.Errorf("%s: got bad lines %v, want %v", name, bad, c.bad)
+				break
+			}
+		}
+	}
+}
+
+func TestOpenAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	if err := os.WriteFile(path, []byte("{\"url\":\"a\"}\n{\"url\":\"b\"}\n{\"url\":\"c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var lines int
+	f, err := OpenAppend(path, func([]byte) { lines++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewNDJSONWriter(f, 0)
+	w.Write(map[string]string{"url": "d"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines != 2 || string(data) != "{\"url\":\"a\"}\n{\"url\":\"b\"}\n{\"url\":\"d\"}\n" {
+		t.Fatalf("got %d lines, file %q", lines, data)
+	}
+}
@@ -0,0 +1,110 @@
+package ioformats
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// NDJSONWriter writes one JSON value per line through a buffer that is
+// flushed at least every interval, so a long run's output reaches disk as
+// it goes. It is safe for concurrent use. The first error sticks and is
+// returned by every later call.
+type NDJSONWriter struct {
+	mu   sync.Mutex
+	bw   *bufio.Writer
+	enc  *json.Encoder
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewNDJSONWriter(w io.Writer, interval time.Duration) *NDJSONWriter {
+	bw := bufio.NewWriterSize(w, 64<<10)
+	nw := &NDJSONWriter{bw: bw, enc: json.NewEncoder(bw), stop: make(chan struct{}), done: make(chan struct{})}
+	if interval <= 0 {
+		close(nw.done)
+		return nw
+	}
+	go func() {
+		defer close(nw.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				_ = nw.Flush()
+			case <-nw.stop:
+				return
+			}
+		}
+	}()
+	return nw
+}
+
+func (w *NDJSONWriter) Write(v any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = w.enc.Encode(v)
+	}
+	return w.err
+}
+
+func (w *NDJSONWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = w.bw.Flush()
+	}
+	return w.err
+}
+
+// Close stops the periodic flush and flushes what is left. It does not
+// close the underlying writer.
+func (w *NDJSONWriter) Close() error {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	<-w.done
+	return w.Flush()
+}
+
+// OpenAppend opens an NDJSON file written by an earlier, possibly killed,
+// run for appending. fn is called with every complete line; a trailing
+// partial line is cut off so new records start on a line of their own. A
+// missing file is created.
+func OpenAppend(path string, fn func(line []byte)) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(f, 64<<10)
+	var end int64 // offset just past the last newline
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		end += int64(len(line))
+		fn(line)
+	}
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,203 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"brightedge-go-crawler/internal/pipeline"
+	"brightedge-go-crawler/internal/webhook"
+)
+
+// CallbackSpec asks for signed POSTs to URL when the job ends and, with
+// Results, for its records in batches as they complete.
+type CallbackSpec struct {
+	URL       string `json:"url"`
+	Secret    string `json:"secret"` // HMAC-SHA256 key, see webhook.Sign; stored apart from job.json
+	Results   bool   `json:"results,omitempty"`
+	BatchSize int    `json:"batchSize,omitempty"` // 1 to 1000; 0 means the default, 100
+}
+
+func (c *CallbackSpec) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("callback url must be an absolute http(s) url")
+	}
+	if c.Secret == "" {
+		return errors.New("callback secret required")
+	}
+	if c.BatchSize < 0 || c.BatchSize > 1000 {
+		return errors.New("callback batchSize must be between 1 and 1000, or 0 for the default of 100")
+	}
+	return nil
+}
+
+func (c *CallbackSpec) batchSize() int {
+	if c.BatchSize == 0 {
+		return 100
+	}
+	return c.BatchSize
+}
+
+// Callback events, sent in the webhook.HeaderEvent header and the payload.
+const (
+	EventResults   = "job.results"
+	EventCompleted = "job.completed"
+)
+
+// resultsWait is how long a partial batch waits for more records.
+const resultsWait = 5 * time.Second
+
+type resultsEvent struct {
+	Event   string            `json:"event"`
+	JobID   string            `json:"jobId"`
+	Offset  int               `json:"offset"`
+	Results []pipeline.Record `json:"results"`
+}
+
+type completedEvent struct {
+	Event string `json:"event"`
+	Job   Status `json:"job"`
+}
+
+// Delivery is one callback in a job's delivery log. A batch that still
+// failed after every retry is logged and skipped; its records remain
+// available from the results API.
+type Delivery struct {
+	ID        string            `json:"id"` // also sent as webhook.HeaderDelivery
+	Event     string            `json:"event"`
+	Offset    int               `json:"offset"` // first record of a results batch
+	Count     int               `json:"count,omitempty"`
+	Delivered bool              `json:"delivered"`
+	Error     string            `json:"error,omitempty"`
+	Attempts  []webhook.Attempt `json:"attempts"`
+}
+
+// logDelivery records d and moves the job's delivery cursor past it.
+// m.mu must be held, or j not yet shared.
+func (j *job) logDelivery(d Delivery) {
+	j.deliveries = append(j.deliveries, d)
+	switch d.Event {
+	case EventResults:
+		j.sent = d.Offset + d.Count
+	case EventCompleted:
+		j.completedSent = true
+	}
+}
+
+func (m *Manager) startDeliveries(j *job) {
+	if j.spec.Callback == nil || m.webhooks == nil || j.completedSent {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.deliver(j)
+	}()
+}
+
+// deliver sends j's callbacks in order: full batches of records as they
+// arrive, a partial one once it has waited resultsWait or the job ended,
+// and finally the completion event.
+func (m *Manager) deliver(j *job) {
+	cb := j.spec.Callback
+	var wait <-chan time.Time
+	for {
+		m.mu.Lock()
+		sent, pending := j.sent, j.status.Completed-j.sent
+		finished, changed := j.status.State.Finished(), j.changed
+		m.mu.Unlock()
+		if !cb.Results {
+			pending = 0
+		}
+
+		switch {
+		case pending >= cb.batchSize() || (pending > 0 && finished):
+			if !m.sendResults(j, sent, min(pending, cb.batchSize())) {
+				return
+			}
+			wait = nil
+			continue
+		case finished:
+			st := m.snapshot(j)
+			m.send(j, Delivery{ID: st.ID + ".completed", Event: EventCompleted}, completedEvent{Event: EventCompleted, Job: st})
+			return
+		case pending > 0 && wait == nil:
+			wait = time.After(resultsWait)
+		}
+		select {
+		case <-changed:
+		case <-wait:
+			if !m.sendResults(j, sent, pending) {
+				return
+			}
+			wait = nil
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) sendResults(j *job, offset, n int) bool {
+	m.mu.Lock()
+	log, err := m.results(j)
+	var recs []pipeline.Record
+	if err == nil {
+		recs, err = log.Read(offset, n)
+	}
+	m.mu.Unlock()
+	d := Delivery{ID: fmt.Sprintf("%s.results.%d", j.status.ID, offset), Event: EventResults, Offset: offset, Count: n}
+	if err != nil {
+		d.Error = "read results: " + err.Error()
+		m.record(j, d)
+		return true
+	}
+	return m.send(j, d, resultsEvent{Event: EventResults, JobID: j.status.ID, Offset: offset, Results: recs})
+}
+
+// send delivers payload as d and logs the outcome. It reports false if the
+// manager is shutting down; the delivery is then not logged and is made
+// again, with the same ID, after a restart.
+func (m *Manager) send(j *job, d Delivery, payload any) bool {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.Error = err.Error()
+		m.record(j, d)
+		return true
+	}
+	cb := j.spec.Callback
+	d.Attempts, err = m.webhooks.Deliver(m.ctx, cb.URL, cb.Secret, d.Event, d.ID, body)
+	if m.ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	d.Delivered = err == nil
+	m.record(j, d)
+	return true
+}
+
+func (m *Manager) record(j *job, d Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.logDelivery(d)
+	if m.store != nil {
+		if err := m.store.appendDelivery(j.status.ID, d); err != nil {
+			j.status.Error = "store delivery: " + err.Error()
+		}
+	}
+}
+
+// Deliveries returns the job's delivery log, oldest first.
+func (m *Manager) Deliveries(id string) ([]Delivery, error) {
+	j, ok := m.get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Delivery{}, j.deliveries...), nil
+}
@@ -0,0 +1,683 @@
+// Package jobs runs crawl jobs in the background and keeps their status and
+// results for polling.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"brightedge-go-crawler/internal/dedup"
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/ioformats"
+	"brightedge-go-crawler/internal/pipeline"
+	"brightedge-go-crawler/internal/urlnorm"
+	"brightedge-go-crawler/internal/webhook"
+)
+
+type State string
+
+const (
+	StateQueued   State = "queued"
+	StateRunning  State = "running"
+	StateDone     State = "done"
+	StateCanceled State = "canceled"
+	StateFailed   State = "failed" // the input could not be read
+)
+
+func (s State) Finished() bool {
+	return s == StateDone || s == StateCanceled || s == StateFailed
+}
+
+// Spec is what a job was submitted with: either URLs or an uploaded File,
+// plus the per-request crawl options of /crawl/batch.
+type Spec struct {
+	URLs         []string         `json:"urls,omitempty"`
+	File         string           `json:"file,omitempty"` // URL file, removed when the job ends
+	Format       ioformats.Format `json:"format,omitempty"`
+	Oversize     string           `json:"oversize,omitempty"`
+	IncludeLinks bool             `json:"includeLinks,omitempty"`
+	Spider       *SpiderSpec      `json:"spider,omitempty"`
+	Callback     *CallbackSpec    `json:"callback,omitempty"`
+}
+
+// SpiderSpec is the JSON form of pipeline.SpiderConfig.
+type SpiderSpec struct {
+	MaxDepth        int    `json:"maxDepth"`
+	Scope           string `json:"scope,omitempty"` // "host" (default), "domain" or "regex"
+	Pattern         string `json:"pattern,omitempty"`
+	MaxPages        int    `json:"maxPages,omitempty"`
+	MaxPagesPerHost int    `json:"maxPagesPerHost,omitempty"`
+	FollowNofollow  bool   `json:"followNofollow,omitempty"`
+}
+
+// Config validates s and converts it, capping MaxPages at maxPages.
+func (s SpiderSpec) Config(maxPages int) (pipeline.SpiderConfig, error) {
+	cfg := pipeline.SpiderConfig{
+		MaxDepth:        s.MaxDepth,
+		MaxPages:        s.MaxPages,
+		MaxPagesPerHost: s.MaxPagesPerHost,
+		FollowNofollow:  s.FollowNofollow,
+	}
+	if maxPages > 0 && (cfg.MaxPages <= 0 || cfg.MaxPages > maxPages) {
+		cfg.MaxPages = maxPages
+	}
+	var err error
+	if cfg.Scope, err = pipeline.ParseScope(s.Scope); err != nil {
+		return cfg, err
+	}
+	if cfg.Scope == pipeline.ScopeRegex {
+		if s.Pattern == "" {
+			return cfg, errors.New("scope regex needs a pattern")
+		}
+		if cfg.Pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// Status is a job's progress as reported by the API.
+type Status struct {
+	ID         string     `json:"jobId"`
+	State      State      `json:"state"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Total      int        `json:"total"`     // input URLs read so far
+	Completed  int        `json:"completed"` // records produced, spider pages included
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	Duplicates int        `json:"duplicates"`
+	BadLines   int        `json:"badLines"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// PipelineFunc returns the pipeline configured for a job's options. It
+// must return a pipeline of its own, e.g. a copy made by WithClient.
+type PipelineFunc func(Spec) (*pipeline.Pipeline, error)
+
+// Manager runs submitted jobs, at most maxRunning at a time, in submission
+// order. With a Store, jobs and results are kept on disk and unfinished jobs
+// continue after a restart; without one they live in memory, and only the
+// most recently finished are kept.
+type Manager struct {
+	pipelineFor PipelineFunc
+	maxPages    int
+	slots       chan struct{}
+	store       *Store
+	webhooks    *webhook.Sender
+	retain      int // finished jobs kept without a store
+	norm        *urlnorm.Normalizer
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+type job struct {
+	spec    Spec
+	status  Status
+	log     resultLog // nil for a finished job restored from the store until read
+	cancel  context.CancelFunc
+	changed chan struct{} // closed and replaced whenever results or status change
+
+	// completed holds the URLs with a record from before a restart.
+	completed dedup.Set
+
+	deliveries    []Delivery
+	sent          int // records covered by results deliveries
+	completedSent bool
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithWebhooks enables job callbacks, delivered through s. Without it jobs
+// with a callback are rejected.
+func WithWebhooks(s *webhook.Sender) Option {
+	return func(m *Manager) { m.webhooks = s }
+}
+
+// DefaultRetention is how many finished jobs a Manager without a store
+// keeps by default.
+const DefaultRetention = 100
+
+// WithRetention keeps at most n finished jobs, results included, when the
+// Manager has no store; older ones are forgotten as others finish.
+func WithRetention(n int) Option {
+	return func(m *Manager) { m.retain = n }
+}
+
+// WithNormalizer sets how input URLs are matched against the records of a
+// resumed job. It should be the jobs' pipeline normalizer; the default is
+// the store's.
+func WithNormalizer(n *urlnorm.Normalizer) Option {
+	return func(m *Manager) { m.norm = n }
+}
+
+// NewManager returns a Manager building pipelines with pipelineFor. Spider
+// jobs are capped at maxSpiderPages pages. store may be nil; otherwise the
+// jobs it holds are loaded and unfinished ones restarted.
+func NewManager(pipelineFor PipelineFunc, maxRunning, maxSpiderPages int, store *Store, opts ...Option) (*Manager, error) {
+	if maxRunning <= 0 {
+		maxRunning = 2
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		pipelineFor: pipelineFor,
+		maxPages:    maxSpiderPages,
+		slots:       make(chan struct{}, maxRunning),
+		store:       store,
+		retain:      DefaultRetention,
+		jobs:        map[string]*job{},
+		ctx:         ctx,
+		stop:        stop,
+	}
+	if store != nil {
+		m.norm = store.norm
+	} else {
+		m.norm = urlnorm.Default
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if err := m.restore(); err != nil {
+		stop()
+		return nil, err
+	}
+	return m, nil
+}
+
+// restore loads stored jobs in creation order and restarts unfinished ones,
+// skipping the URLs they already have records for. Spider jobs cannot pick
+// up their link frontier again and are marked failed instead.
+func (m *Manager) restore() error {
+	if m.store == nil {
+		return nil
+	}
+	files, err := m.store.loadJobs()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(a, b int) bool { return files[a].Status.CreatedAt.Before(files[b].Status.CreatedAt) })
+	for _, jf := range files {
+		j := &job{spec: jf.Spec, status: jf.Status, changed: make(chan struct{})}
+		st := &j.status
+		if !st.State.Finished() {
+			// counts are rebuilt from the records; the input is recounted
+			// as it is read again
+			j.completed = dedup.NewExact()
+			st.Total, st.Completed, st.Succeeded, st.Failed, st.Duplicates, st.BadLines = 0, 0, 0, 0, 0, 0
+			if j.log, err = m.store.openLog(st.ID, func(rec pipeline.Record) {
+				count(st, rec)
+				j.completed.Add(m.norm.Key(rec.URL))
+			}); err != nil {
+				return err
+			}
+		}
+		ds, err := m.store.loadDeliveries(st.ID)
+		if err != nil {
+			return err
+		}
+		for _, d := range ds {
+			j.logDelivery(d)
+		}
+		m.jobs[st.ID] = j
+		switch {
+		case st.State.Finished():
+			// its results are indexed when first asked for
+		case j.spec.Spider != nil:
+			j.completed = nil
+			m.end(j, StateFailed, "interrupted by a server restart; spider jobs cannot resume")
+		default:
+			if err := m.start(j); err != nil {
+				m.end(j, StateFailed, err.Error())
+			}
+		}
+		m.startDeliveries(j)
+	}
+	return nil
+}
+
+// Submit validates spec and queues it. Invalid options are reported here
+// rather than as a failed job.
+func (m *Manager) Submit(spec Spec) (Status, error) {
+	if len(spec.URLs) == 0 && spec.File == "" {
+		return Status{}, errors.New("no urls")
+	}
+	pl, spider, err := m.prepare(spec)
+	if err != nil {
+		return Status{}, err
+	}
+	if spec.Callback != nil {
+		if m.webhooks == nil {
+			return Status{}, errors.New("callbacks are not enabled")
+		}
+		if err := spec.Callback.validate(); err != nil {
+			return Status{}, err
+		}
+	}
+
+	// the job is cancelable from the moment it is published
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		spec:    spec,
+		status:  Status{ID: newID(), State: StateQueued, CreatedAt: time.Now().UTC()},
+		log:     &memLog{},
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+	if m.store != nil {
+		if err := m.storeNew(j); err != nil {
+			cancel()
+			m.store.removeJob(j.status.ID)
+			return Status{}, err
+		}
+	}
+	m.mu.Lock()
+	m.jobs[j.status.ID] = j
+	m.mu.Unlock()
+	m.launch(ctx, cancel, j, pl, spider)
+	m.startDeliveries(j)
+	return m.snapshot(j), nil
+}
+
+// storeNew saves a submitted job and opens its results, taking over its
+// uploaded input.
+func (m *Manager) storeNew(j *job) error {
+	var err error
+	if j.spec.File != "" {
+		if j.spec.File, err = m.store.adoptInput(j.status.ID, j.spec.File); err != nil {
+			return err
+		}
+	}
+	if j.log, err = m.store.openLog(j.status.ID, func(pipeline.Record) {}); err != nil {
+		return err
+	}
+	if err := m.store.saveJob(j.spec, j.status); err != nil {
+		j.log.Close()
+		return err
+	}
+	return nil
+}
+
+// prepare builds the pipeline and spider settings for spec.
+func (m *Manager) prepare(spec Spec) (*pipeline.Pipeline, pipeline.SpiderConfig, error) {
+	var spider pipeline.SpiderConfig
+	pl, err := m.pipelineFor(spec)
+	if err != nil {
+		return nil, spider, err
+	}
+	if spec.Spider != nil {
+		if spider, err = spec.Spider.Config(m.maxPages); err != nil {
+			return nil, spider, err
+		}
+	}
+	return pl, spider, nil
+}
+
+// start runs a job restored from the store.
+func (m *Manager) start(j *job) error {
+	pl, spider, err := m.prepare(j.spec)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.mu.Lock()
+	j.cancel = cancel
+	m.mu.Unlock()
+	m.launch(ctx, cancel, j, pl, spider)
+	return nil
+}
+
+func (m *Manager) launch(ctx context.Context, cancel context.CancelFunc, j *job, pl *pipeline.Pipeline, spider pipeline.SpiderConfig) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+		m.run(ctx, j, pl, spider)
+	}()
+}
+
+func (m *Manager) run(ctx context.Context, j *job, pl *pipeline.Pipeline, spider pipeline.SpiderConfig) {
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		m.end(j, StateCanceled, "")
+		return
+	}
+	m.setState(j, func(s *Status) {
+		now := time.Now().UTC()
+		s.State, s.StartedAt = StateRunning, &now
+	})
+
+	urls, readErr := m.input(ctx, j)
+	counted := make(chan string)
+	go func() {
+		defer close(counted)
+		for u := range urls {
+			m.update(j, func(s *Status) { s.Total++ })
+			if j.completed != nil && j.completed.Has(m.norm.Key(u)) {
+				continue
+			}
+			select {
+			case counted <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// duplicates keep a record so results account for every input URL
+	pl.EmitDuplicates = true
+	emit := func(rec pipeline.Record) { m.add(j, rec) }
+	if j.spec.Spider != nil {
+		pl.Spider(ctx, counted, spider, emit)
+	} else {
+		pl.Run(ctx, counted, emit)
+	}
+
+	switch err := <-readErr; {
+	case ctx.Err() != nil:
+		m.end(j, StateCanceled, "")
+	case err != nil:
+		m.end(j, StateFailed, "read input: "+err.Error())
+	default:
+		m.end(j, StateDone, "")
+	}
+}
+
+// end finishes j unless the manager itself is shutting down, in which case
+// the job stays unfinished on disk and resumes on the next start.
+func (m *Manager) end(j *job, state State, errMsg string) {
+	if m.ctx.Err() != nil && m.store != nil {
+		return
+	}
+	m.mu.Lock()
+	j.log.Close() // every record is in; reads reopen the file as needed
+	m.mu.Unlock()
+	m.finish(j, state, errMsg)
+	if j.spec.File != "" {
+		os.Remove(j.spec.File)
+	}
+}
+
+// evict forgets the oldest finished jobs beyond the retention limit. m.mu
+// must be held.
+func (m *Manager) evict() {
+	var done []*job
+	for _, j := range m.jobs {
+		if j.status.State.Finished() {
+			done = append(done, j)
+		}
+	}
+	if len(done) <= m.retain {
+		return
+	}
+	sort.Slice(done, func(a, b int) bool { return done[a].status.FinishedAt.Before(*done[b].status.FinishedAt) })
+	for _, j := range done[:len(done)-m.retain] {
+		delete(m.jobs, j.status.ID)
+	}
+}
+
+// results returns j's log, indexing a restored finished job's results on
+// first use. m.mu must be held.
+func (m *Manager) results(j *job) (resultLog, error) {
+	if j.log == nil {
+		l, err := m.store.finishedLog(j.status.ID)
+		if err != nil {
+			return nil, err
+		}
+		j.log = l
+	}
+	return j.log, nil
+}
+
+// input streams the job's URLs. Bad lines of an uploaded file become
+// invalid_url records.
+func (m *Manager) input(ctx context.Context, j *job) (<-chan string, <-chan error) {
+	if j.spec.File == "" {
+		errc := make(chan error, 1)
+		errc <- nil
+		return pipeline.Feed(ctx, j.spec.URLs), errc
+	}
+	f, err := os.Open(j.spec.File)
+	if err != nil {
+		return failedInput(err)
+	}
+	r, err := ioformats.NewReader(f, j.spec.Format)
+	if err != nil {
+		f.Close()
+		return failedInput(err)
+	}
+	urls, errc := ioformats.Stream(ctx, r, func(le *ioformats.LineError) {
+		m.update(j, func(s *Status) { s.BadLines++ })
+		if j.completed != nil && j.completed.Has(m.norm.Key(le.Text)) {
+			return
+		}
+		m.add(j, pipeline.Record{URL: le.Text, Error: le.Error(), ErrorCode: fetcherr.CodeInvalidURL})
+	})
+	done := make(chan error, 1)
+	go func() {
+		err := <-errc
+		f.Close()
+		done <- err
+	}()
+	return urls, done
+}
+
+func failedInput(err error) (<-chan string, <-chan error) {
+	urls, errc := make(chan string), make(chan error, 1)
+	close(urls)
+	errc <- err
+	return urls, errc
+}
+
+func (m *Manager) add(j *job, rec pipeline.Record) {
+	// URLs cut off by shutdown are not done; they run again after restart
+	if m.ctx.Err() != nil && m.store != nil && rec.ErrorCode == fetcherr.CodeCanceled {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := j.log.Append(rec); err != nil {
+		j.status.Error = "store result: " + err.Error()
+		return
+	}
+	if m.store != nil && rec.Result != nil {
+		_ = m.store.saveURL(j.status.ID, rec) // only costs the by-URL lookup
+	}
+	count(&j.status, rec)
+	j.notify()
+}
+
+func count(s *Status, rec pipeline.Record) {
+	s.Completed++
+	switch {
+	case rec.DuplicateOf != "":
+		s.Duplicates++
+	case rec.Result != nil:
+		s.Succeeded++
+	default:
+		s.Failed++
+	}
+}
+
+func (m *Manager) update(j *job, fn func(*Status)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&j.status)
+	j.notify()
+}
+
+// setState is update for state changes, which are also saved to the store.
+func (m *Manager) setState(j *job, fn func(*Status)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&j.status)
+	if m.store != nil {
+		if err := m.store.saveJob(j.spec, j.status); err != nil {
+			j.status.Error = "store job: " + err.Error()
+		}
+	}
+	j.notify()
+}
+
+func (m *Manager) finish(j *job, state State, errMsg string) {
+	m.setState(j, func(s *Status) {
+		now := time.Now().UTC()
+		s.State, s.FinishedAt, s.Error = state, &now, errMsg
+		if m.store == nil {
+			m.evict()
+		}
+	})
+}
+
+// notify wakes everyone following the job. m.mu must be held.
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (m *Manager) snapshot(j *job) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return j.status
+}
+
+func (m *Manager) get(id string) (*job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// ErrFinished is returned when cancelling a job that already ended.
+var ErrFinished = errors.New("job already finished")
+
+func (m *Manager) Status(id string) (Status, error) {
+	j, ok := m.get(id)
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	return m.snapshot(j), nil
+}
+
+// Results returns up to limit records starting at offset, in completion
+// order, and the job's status at that moment.
+func (m *Manager) Results(id string, offset, limit int) ([]pipeline.Record, Status, error) {
+	j, ok := m.get(id)
+	if !ok {
+		return nil, Status{}, ErrNotFound
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log, err := m.results(j)
+	if err != nil {
+		return nil, j.status, err
+	}
+	offset = min(max(offset, 0), log.Len())
+	n := log.Len() - offset
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	recs, err := log.Read(offset, n)
+	return recs, j.status, err
+}
+
+// Follow calls fn for every record from offset on, waiting for new ones
+// until the job finishes, ctx ends or fn fails.
+func (m *Manager) Follow(ctx context.Context, id string, offset int, fn func(pipeline.Record) error) error {
+	j, ok := m.get(id)
+	if !ok {
+		return ErrNotFound
+	}
+	for {
+		m.mu.Lock()
+		log, err := m.results(j)
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		offset = min(max(offset, 0), log.Len())
+		recs, err := log.Read(offset, min(log.Len()-offset, 1000))
+		more := offset+len(recs) < log.Len()
+		finished, changed := j.status.State.Finished(), j.changed
+		m.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range recs {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		offset += len(recs)
+		if more {
+			continue
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Cancel stops a queued or running job. Records already produced are kept.
+func (m *Manager) Cancel(id string) (Status, error) {
+	j, ok := m.get(id)
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	m.mu.Lock()
+	st, cancel := j.status, j.cancel
+	m.mu.Unlock()
+	if st.State.Finished() {
+		return st, ErrFinished
+	}
+	if cancel != nil {
+		cancel()
+	}
+	return st, nil
+}
+
+// Close stops every job and waits for them. With a store, running jobs are
+// left unfinished on disk so the next Manager resumes them.
+func (m *Manager) Close() {
+	m.stop()
+	m.wg.Wait()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.log != nil {
+			j.log.Close()
+		}
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("jobs: reading random id: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
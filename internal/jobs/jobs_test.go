@@ -0,0 +1,282 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"brightedge-go-crawler/internal/crawler"
+	"brightedge-go-crawler/internal/pipeline"
+	"brightedge-go-crawler/internal/urlnorm"
+	"brightedge-go-crawler/internal/webhook"
+)
+
+func TestManager(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(10*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	base := pipeline.New(pipeline.Config{Client: client})
+	m, err := NewManager(func(Spec) (*pipeline.Pipeline, error) { return base.WithClient(client), nil }, 1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	st, err := m.Submit(Spec{URLs: []string{ts.URL + "/a", ts.URL + "/b", ts.URL + "/a"}})
+	if err != nil || st.State != StateQueued {
+		t.Fatalf("submit: %+v, %v", st, err)
+	}
+	var got []pipeline.Record
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Follow(ctx, st.ID, 0, func(r pipeline.Record) error { got = append(got, r); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	st, _ = m.Status(st.ID)
+	if st.State != StateDone || st.Total != 3 || st.Succeeded != 2 || st.Duplicates != 1 || len(got) != 3 {
+		t.Fatalf("unexpected status %+v with %d records", st, len(got))
+	}
+	if page, _, _ := m.Results(st.ID, 2, 10); len(page) != 1 {
+		t.Fatalf("want 1 record at offset 2, got %d", len(page))
+	}
+
+	slow, _ := m.Submit(Spec{URLs: []string{ts.URL + "/slow"}})
+	if _, err := m.Cancel(slow.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Follow(ctx, slow.ID, 0, func(pipeline.Record) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := m.Status(slow.ID); st.State != StateCanceled {
+		t.Fatalf("want canceled, got %+v", st)
+	}
+	if _, err := m.Cancel(slow.ID); err != ErrFinished {
+		t.Fatalf("want ErrFinished cancelling twice, got %v", err)
+	}
+	if _, err := m.Status("nope"); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+
+	// a restored job whose pipeline could not be built has no cancel func
+	m.mu.Lock()
+	m.jobs["stuck"] = &job{status: Status{ID: "stuck", State: StateQueued}, changed: make(chan struct{})}
+	m.mu.Unlock()
+	if _, err := m.Cancel("stuck"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManagerResume(t *testing.T) {
+	var blockSlow atomic.Bool
+	blockSlow.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" && blockSlow.Load() {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(10*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	norm := urlnorm.New("ref")
+	base := pipeline.New(pipeline.Config{Client: client, Normalizer: norm})
+	pipelineFor := func(Spec) (*pipeline.Pipeline, error) { return base.WithClient(client), nil }
+	store, err := OpenStore(t.TempDir(), norm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m1, err := NewManager(pipelineFor, 1, 0, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := m1.Submit(Spec{URLs: []string{ts.URL + "/fast", ts.URL + "/slow"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFirst := errors.New("first record")
+	if err := m1.Follow(ctx, st.ID, 0, func(pipeline.Record) error { return errFirst }); err != errFirst {
+		t.Fatal(err)
+	}
+	m1.Close() // "restart" while /slow is in flight
+
+	blockSlow.Store(false)
+	m2, err := NewManager(pipelineFor, 1, 0, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m2.Close()
+	var urls []string
+	if err := m2.Follow(ctx, st.ID, 0, func(r pipeline.Record) error { urls = append(urls, r.URL); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := m2.Status(st.ID); st.State != StateDone || st.Succeeded != 2 || len(urls) != 2 || urls[1] != ts.URL+"/slow" {
+		t.Fatalf("unexpected resumed job %+v with records %v", st, urls)
+	}
+	if res, err := store.Lookup(ts.URL + "/slow?ref=mail"); err != nil || res.JobID != st.ID {
+		t.Fatalf("lookup by url: %+v, %v", res, err)
+	}
+	if l := m2.jobs[st.ID].log.(*fileLog); l.f != nil {
+		t.Fatal("results file left open after the job finished")
+	}
+
+	m3, err := NewManager(pipelineFor, 1, 0, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m3.Close()
+	if m3.jobs[st.ID].log != nil {
+		t.Fatal("finished job's results opened on restore")
+	}
+	if recs, _, err := m3.Results(st.ID, 0, 10); err != nil || len(recs) != 2 || m3.jobs[st.ID].log.(*fileLog).f != nil {
+		t.Fatalf("results of a finished job: %d records, %v", len(recs), err)
+	}
+}
+
+func TestManagerRetention(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(10*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	base := pipeline.New(pipeline.Config{Client: client})
+	m, err := NewManager(func(Spec) (*pipeline.Pipeline, error) { return base.WithClient(client), nil }, 1, 0, nil, WithRetention(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var ids []string
+	for i := 0; i < 3; i++ {
+		st, err := m.Submit(Spec{URLs: []string{ts.URL + "/"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Follow(ctx, st.ID, 0, func(pipeline.Record) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, st.ID)
+	}
+	if _, err := m.Status(ids[0]); err != ErrNotFound {
+		t.Fatalf("want the oldest finished job dropped, got %v", err)
+	}
+	for _, id := range ids[1:] {
+		if recs, _, err := m.Results(id, 0, 10); err != nil || len(recs) != 1 {
+			t.Fatalf("job %s: %d records, %v", id, len(recs), err)
+		}
+	}
+}
+
+func TestManagerCallbacks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var events []string
+	var results int
+	var hits int
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if hits++; hits == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // retried
+			return
+		}
+		if !webhook.Verify("s3cret", r.Header, body) {
+			t.Errorf("bad signature on %s", body)
+		}
+		var ev resultsEvent
+		json.Unmarshal(body, &ev)
+		events = append(events, ev.Event)
+		results += len(ev.Results)
+	}))
+	defer hook.Close()
+
+	client := crawler.NewHTTPClient(10*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	base := pipeline.New(pipeline.Config{Client: client})
+	sender := webhook.NewSender(client, webhook.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	m, err := NewManager(func(Spec) (*pipeline.Pipeline, error) { return base.WithClient(client), nil }, 1, 0, nil, WithWebhooks(sender))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if _, err := m.Submit(Spec{URLs: []string{ts.URL}, Callback: &CallbackSpec{URL: hook.URL}}); err == nil {
+		t.Fatal("want an error for a callback without a secret")
+	}
+	st, err := m.Submit(Spec{
+		URLs:     []string{ts.URL + "/a", ts.URL + "/b", ts.URL + "/c"},
+		Callback: &CallbackSpec{URL: hook.URL, Secret: "s3cret", Results: true, BatchSize: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ds []Delivery
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if ds, _ = m.Deliveries(st.ID); len(ds) > 0 && ds[len(ds)-1].Event == EventCompleted {
+			break
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ds) != 3 || !ds[2].Delivered || len(ds[0].Attempts) != 2 || results != 3 ||
+		len(events) != 3 || events[2] != EventCompleted {
+		t.Fatalf("unexpected deliveries %+v, events %v with %d results", ds, events, results)
+	}
+}
+
+func TestStoreCallbackSecret(t *testing.T) {
+	store, err := OpenStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := Spec{URLs: []string{"https://example.com/"}, Callback: &CallbackSpec{URL: "https://hooks.example/", Secret: "s3cret"}}
+	st := Status{ID: "j1", State: StateDone}
+	if err := store.saveJob(spec, st); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(store.jobDir("j1"), "job.json"))
+	if err != nil || strings.Contains(string(data), "s3cret") {
+		t.Fatalf("secret written to job.json: %s, %v", data, err)
+	}
+	files, err := store.loadJobs()
+	if err != nil || len(files) != 1 || files[0].Spec.Callback.Secret != "s3cret" || spec.Callback.Secret != "s3cret" {
+		t.Fatalf("secret not restored: %+v, %v", files, err)
+	}
+	if (&CallbackSpec{URL: "https://hooks.example/", Secret: "x"}).validate() != nil {
+		t.Fatal("batchSize 0 should mean the default")
+	}
+}
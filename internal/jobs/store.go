@@ -0,0 +1,391 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"brightedge-go-crawler/internal/pipeline"
+	"brightedge-go-crawler/internal/urlnorm"
+)
+
+// Store keeps jobs on disk so they survive restarts:
+//
+//	jobs/<id>/job.json        spec and status
+//	jobs/<id>/callback.secret  callback secret, kept out of job.json
+//	jobs/<id>/input           uploaded URL file, until the job ends
+//	jobs/<id>/results.ndjson  records, in completion order
+//	jobs/<id>/deliveries.ndjson  callback delivery log
+//	urls/<hh>/<hash>.json     latest successful record per normalized URL
+type Store struct {
+	dir  string
+	norm *urlnorm.Normalizer
+}
+
+// OpenStore opens or creates a store in dir. Results are looked up by URL
+// in norm's normalized form; nil uses urlnorm.Default.
+func OpenStore(dir string, norm *urlnorm.Normalizer) (*Store, error) {
+	if norm == nil {
+		norm = urlnorm.Default
+	}
+	for _, sub := range []string{"jobs", "urls"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dir: dir, norm: norm}, nil
+}
+
+type jobFile struct {
+	Spec   Spec   `json:"spec"`
+	Status Status `json:"status"`
+}
+
+// URLResult is the latest successful record stored for a URL.
+type URLResult struct {
+	JobID  string          `json:"jobId"`
+	Record pipeline.Record `json:"record"`
+}
+
+// ErrNoResult is returned by Lookup for URLs without a stored result.
+var ErrNoResult = errors.New("no stored result")
+
+func (s *Store) jobDir(id string) string { return filepath.Join(s.dir, "jobs", id) }
+
+func (s *Store) saveJob(spec Spec, st Status) error {
+	if err := os.MkdirAll(s.jobDir(st.ID), 0o755); err != nil {
+		return err
+	}
+	if cb := spec.Callback; cb != nil {
+		// writeAtomic's temp files are private to the owner
+		if err := writeAtomic(filepath.Join(s.jobDir(st.ID), "callback.secret"), []byte(cb.Secret)); err != nil {
+			return err
+		}
+		redacted := *cb
+		redacted.Secret = ""
+		spec.Callback = &redacted
+	}
+	data, err := json.Marshal(jobFile{Spec: spec, Status: st})
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(s.jobDir(st.ID), "job.json"), data)
+}
+
+func (s *Store) loadJobs() ([]jobFile, error) {
+	dirs, err := os.ReadDir(filepath.Join(s.dir, "jobs"))
+	if err != nil {
+		return nil, err
+	}
+	var out []jobFile
+	for _, d := range dirs {
+		data, err := os.ReadFile(filepath.Join(s.jobDir(d.Name()), "job.json"))
+		if err != nil {
+			continue // submit did not get as far as saving it
+		}
+		var jf jobFile
+		if err := json.Unmarshal(data, &jf); err != nil {
+			return nil, err
+		}
+		if jf.Spec.Callback != nil {
+			secret, err := os.ReadFile(filepath.Join(s.jobDir(d.Name()), "callback.secret"))
+			if err != nil {
+				return nil, err
+			}
+			jf.Spec.Callback.Secret = string(secret)
+		}
+		out = append(out, jf)
+	}
+	return out, nil
+}
+
+// removeJob deletes everything stored for a job.
+func (s *Store) removeJob(id string) error {
+	return os.RemoveAll(s.jobDir(id))
+}
+
+// adoptInput moves an uploaded file into the job's directory.
+func (s *Store) adoptInput(id, path string) (string, error) {
+	if err := os.MkdirAll(s.jobDir(id), 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(s.jobDir(id), "input")
+	if err := os.Rename(path, dst); err == nil {
+		return dst, nil
+	}
+	// different filesystem: copy
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	os.Remove(path)
+	return dst, nil
+}
+
+func (s *Store) appendDelivery(id string, d Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.jobDir(id), "deliveries.ndjson"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadDeliveries returns the job's delivery log, ignoring a partial last line.
+func (s *Store) loadDeliveries(id string) ([]Delivery, error) {
+	data, err := os.ReadFile(filepath.Join(s.jobDir(id), "deliveries.ndjson"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Delivery
+	for _, line := range bytes.SplitAfter(data, []byte{'\n'}) {
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			continue
+		}
+		var d Delivery
+		if err := json.Unmarshal(line, &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (s *Store) urlPath(rawURL string) string {
+	sum := sha256.Sum256([]byte(s.norm.Key(rawURL)))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, "urls", name[:2], name+".json")
+}
+
+func (s *Store) saveURL(jobID string, rec pipeline.Record) error {
+	p := s.urlPath(rec.URL)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(URLResult{JobID: jobID, Record: rec})
+	if err != nil {
+		return err
+	}
+	return writeAtomic(p, data)
+}
+
+// Lookup returns the latest successful record crawled for rawURL by any job.
+func (s *Store) Lookup(rawURL string) (URLResult, error) {
+	data, err := os.ReadFile(s.urlPath(rawURL))
+	if errors.Is(err, os.ErrNotExist) {
+		return URLResult{}, ErrNoResult
+	}
+	if err != nil {
+		return URLResult{}, err
+	}
+	var r URLResult
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resultLog holds a job's records in completion order. Close ends appends
+// and releases open files; a closed log can still be read.
+type resultLog interface {
+	Append(pipeline.Record) error
+	Len() int
+	Read(offset, limit int) ([]pipeline.Record, error)
+	Close() error
+}
+
+type memLog struct{ recs []pipeline.Record }
+
+func (l *memLog) Append(rec pipeline.Record) error {
+	l.recs = append(l.recs, rec)
+	return nil
+}
+
+func (l *memLog) Len() int { return len(l.recs) }
+
+func (l *memLog) Read(offset, limit int) ([]pipeline.Record, error) {
+	return append([]pipeline.Record(nil), l.recs[offset:offset+limit]...), nil
+}
+
+func (l *memLog) Close() error { return nil }
+
+// fileLog appends records to an NDJSON file and keeps each line's offset
+// so pages can be read back without scanning. Once closed it still serves
+// reads, opening the file only for the duration of each one.
+type fileLog struct {
+	path    string
+	f       *os.File // nil once closed
+	offsets []int64  // start of each line, plus the end of the last one
+}
+
+func (s *Store) logPath(id string) string { return filepath.Join(s.jobDir(id), "results.ndjson") }
+
+// openLog opens the job's results, calling fn for every stored record. A
+// partial last line, from a crash mid-write, is cut off.
+func (s *Store) openLog(id string, fn func(pipeline.Record)) (*fileLog, error) {
+	if err := os.MkdirAll(s.jobDir(id), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.logPath(id), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &fileLog{path: s.logPath(id), f: f, offsets: []int64{0}}
+	br := bufio.NewReaderSize(f, 64<<10)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var rec pipeline.Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			f.Close()
+			return nil, err
+		}
+		l.offsets = append(l.offsets, l.offsets[len(l.offsets)-1]+int64(len(line)))
+		fn(rec)
+	}
+	end := l.offsets[len(l.offsets)-1]
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// finishedLog indexes the results of a finished job and returns them as a
+// closed log, holding no file open.
+func (s *Store) finishedLog(id string) (*fileLog, error) {
+	f, err := os.Open(s.logPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return &fileLog{path: s.logPath(id), offsets: []int64{0}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l := &fileLog{path: s.logPath(id), offsets: []int64{0}}
+	br := bufio.NewReaderSize(f, 64<<10)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		l.offsets = append(l.offsets, l.offsets[len(l.offsets)-1]+int64(len(line)))
+	}
+}
+
+// errLogClosed is returned when appending to a finished job's results.
+var errLogClosed = errors.New("results log closed")
+
+func (l *fileLog) Append(rec pipeline.Record) error {
+	if l.f == nil {
+		return errLogClosed
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := l.f.Write(data); err != nil {
+		return err
+	}
+	l.offsets = append(l.offsets, l.offsets[len(l.offsets)-1]+int64(len(data)))
+	return nil
+}
+
+func (l *fileLog) Len() int { return len(l.offsets) - 1 }
+
+func (l *fileLog) Read(offset, limit int) ([]pipeline.Record, error) {
+	if limit == 0 {
+		return nil, nil
+	}
+	f := l.f
+	if f == nil {
+		var err error
+		if f, err = os.Open(l.path); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+	start, end := l.offsets[offset], l.offsets[offset+limit]
+	buf := make([]byte, end-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+	recs := make([]pipeline.Record, 0, limit)
+	for _, line := range bytes.SplitAfter(buf, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		var rec pipeline.Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+func (l *fileLog) Close() error {
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,85 @@
+package langid
+
+// stopwords are each language's most common function words. They seed the
+// n-gram profiles and are exported through Stopwords for topic extraction.
+var stopwords = map[string]string{
+	"en": `the and of to in a for is on with as by at from that this it an be or are was will has have had but not
+your you we our they their he she his her its which who what when where there been were would can could about
+into more than also just only other some such then them these those than up out if do does did so no all any`,
+	"de": `der die das und ist nicht ein eine einen einem einer den dem des zu mit auf für von im in an als auch
+es sich wir sie er ich du ihr sind war wird werden wurde hat haben bei aus nach noch nur oder aber wie wenn so
+dass über um vor zum zur durch man kann mehr schon sehr unter diese dieser dieses sein seine ihre uns`,
+	"fr": `le la les de des du un une et est en que qui dans pour pas sur au aux avec ce cette ces il elle ils elles
+nous vous on se sa son ses leur leurs mais ou plus par sont été être avoir a ont fait comme tout tous très aussi
+bien entre depuis sans sous chez lui y ne même après avant donc alors encore`,
+	"es": `el la los las de del un una unos unas y que en es por para con no se su sus al lo como más pero o
+este esta estos estas ese esa fue son ser está están ha han hay muy sin sobre también entre hasta desde todo
+todos cuando donde porque le les nos ya sí yo tu él ella ellos nosotros otro otra`,
+	"it": `il lo la i gli le di del della dei delle un una uno e è che in per con non si sono al alla ai nel nella
+da dal dalla come più ma o anche questo questa questi quello quella suo sua loro ci ne mi ti se tra fra molto
+tutto tutti stato essere ha hanno era quando dove perché già ancora dopo prima così`,
+	"pt": `o a os as de do da dos das um uma e é que em no na nos nas por para com não se seu sua seus suas ao
+à como mais mas ou este esta isso esse essa foi são ser está estão tem têm há muito sem sobre também entre até
+desde todo todos quando onde porque lhe eles elas nós você já ainda depois`,
+	"nl": `de het een en van in is dat op te voor met niet zijn er aan als ook door bij om maar dan of uit naar
+over wordt worden werd heeft hebben had was deze dit die wat wie waar hoe we wij ze zij hij ik je jij u ons hun
+haar zijn nog al meer veel kan moet zal geen tot onder tussen omdat`,
+	"sv": `och i att det som en på är av för med till den inte har de om ett var jag han hon vi ni så men från
+kan eller vid sig man när sin sina hade blev bli är detta denna dessa där här också efter under över mellan
+utan bara mycket alla andra vara varit ska skulle sedan än`,
+	"pl": `i w na z do się nie że to jest jak od po za o co ale przez dla tak jego jej ich są był była było być
+może już tylko także oraz lub czy gdy który która które tym ten ta te tego tej przy pod nad między bez jeszcze
+bardzo wszystko wszyscy kiedy gdzie dlaczego`,
+	"tr": `ve bir bu da de ile için olarak olan çok daha gibi en ne ama veya ya şu o ben sen biz siz onlar
+kadar sonra önce her şey var yok değil mi mı mu mü ise ki göre ancak hem diye olduğu olduğunu tarafından
+üzerinde arasında içinde bunu buna şimdi yeni`,
+	"ru": `и в во не что он на я с со как а то все она так его но да ты к у же вы за бы по только ее мне было
+вот от меня еще нет о из ему теперь когда даже ну вдруг ли если уже или ни быть был него до вас нибудь опять
+уж вам ведь там потом себя ничего ей может они тут где есть надо ней для мы тебя их чем была сам чтоб без будто
+это этот эта эти который которые также`,
+}
+
+// samples are short passages of ordinary prose that give the profiles the
+// letter sequences of content words.
+var samples = map[string]string{
+	"en": `The weather was warm and the city was full of people walking through the streets. Government officials
+said on Thursday that the new policy would help small businesses grow, although several economists warned that
+prices could continue rising throughout the year. Our customers can order online and receive their products within
+two working days. Read the latest news, reviews and stories about technology, science and health.`,
+	"de": `Das Wetter war warm und die Stadt war voller Menschen, die durch die Straßen gingen. Die Regierung
+erklärte am Donnerstag, dass die neue Regelung kleinen Unternehmen beim Wachstum helfen werde, obwohl mehrere
+Ökonomen warnten, dass die Preise im Laufe des Jahres weiter steigen könnten. Unsere Kunden können online
+bestellen und erhalten ihre Produkte innerhalb von zwei Werktagen. Lesen Sie aktuelle Nachrichten und Berichte.`,
+	"fr": `Le temps était doux et la ville était pleine de gens qui se promenaient dans les rues. Le gouvernement
+a déclaré jeudi que la nouvelle politique aiderait les petites entreprises à se développer, bien que plusieurs
+économistes aient averti que les prix pourraient continuer à augmenter tout au long de l'année. Nos clients
+peuvent commander en ligne et recevoir leurs produits en deux jours ouvrables. Lisez les dernières actualités.`,
+	"es": `El tiempo era cálido y la ciudad estaba llena de gente que caminaba por las calles. El gobierno
+afirmó el jueves que la nueva política ayudaría a crecer a las pequeñas empresas, aunque varios economistas
+advirtieron que los precios podrían seguir subiendo durante todo el año. Nuestros clientes pueden hacer su
+pedido en línea y recibir sus productos en dos días hábiles. Lea las últimas noticias y reportajes.`,
+	"it": `Il tempo era mite e la città era piena di persone che passeggiavano per le strade. Il governo ha
+dichiarato giovedì che la nuova politica aiuterà le piccole imprese a crescere, anche se diversi economisti
+hanno avvertito che i prezzi potrebbero continuare a salire per tutto l'anno. I nostri clienti possono
+ordinare online e ricevere i prodotti entro due giorni lavorativi. Leggi le ultime notizie e gli articoli.`,
+	"pt": `O tempo estava quente e a cidade estava cheia de pessoas que caminhavam pelas ruas. O governo
+afirmou na quinta-feira que a nova política vai ajudar as pequenas empresas a crescer, embora vários
+economistas tenham alertado que os preços podem continuar subindo ao longo do ano. Nossos clientes podem
+fazer o pedido pela internet e receber os produtos em dois dias úteis. Leia as últimas notícias e reportagens.`,
+	"nl": `Het weer was warm en de stad was vol mensen die door de straten liepen. De regering zei donderdag
+dat het nieuwe beleid kleine bedrijven zou helpen groeien, hoewel verschillende economen waarschuwden dat de
+prijzen het hele jaar door zouden kunnen blijven stijgen. Onze klanten kunnen online bestellen en ontvangen
+hun producten binnen twee werkdagen. Lees het laatste nieuws, recensies en verhalen over technologie.`,
+	"sv": `Vädret var varmt och staden var full av människor som promenerade genom gatorna. Regeringen sade
+på torsdagen att den nya politiken skulle hjälpa små företag att växa, även om flera ekonomer varnade för
+att priserna kunde fortsätta att stiga under hela året. Våra kunder kan beställa på nätet och få sina
+produkter inom två arbetsdagar. Läs de senaste nyheterna, recensionerna och berättelserna om teknik.`,
+	"pl": `Pogoda była ciepła, a miasto pełne ludzi spacerujących po ulicach. Rząd poinformował w czwartek,
+że nowa polityka pomoże rozwijać się małym firmom, chociaż kilku ekonomistów ostrzegło, że ceny mogą rosnąć
+przez cały rok. Nasi klienci mogą zamawiać przez internet i otrzymać swoje produkty w ciągu dwóch dni
+roboczych. Przeczytaj najnowsze wiadomości, recenzje i artykuły o technologii, nauce i zdrowiu.`,
+	"tr": `Hava sıcaktı ve şehir sokaklarda yürüyen insanlarla doluydu. Hükümet perşembe günü yeni politikanın
+küçük işletmelerin büyümesine yardımcı olacağını açıkladı, ancak birçok ekonomist fiyatların yıl boyunca
+artmaya devam edebileceği konusunda uyardı. Müşterilerimiz internetten sipariş verebilir ve ürünlerini iki
+iş günü içinde teslim alabilir. Teknoloji, bilim ve sağlık hakkında en son haberleri okuyun.`,
+}
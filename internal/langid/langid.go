@@ -0,0 +1,214 @@
+// Package langid identifies the language of a text. Scripts used by a
+// single language (Greek, Hangul, Thai, ...) decide on their own; Latin and
+// Cyrillic text is scored against character trigram profiles.
+package langid
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxRunes bounds the text examined; more rarely changes the answer.
+const maxRunes = 4000
+
+// Result is a detected language.
+type Result struct {
+	Lang       string  // ISO 639-1 code, "" when undecided
+	Confidence float64 // 0..1
+}
+
+type profile struct {
+	logp   map[string]float64
+	unseen float64 // log-probability of a trigram not in the profile
+}
+
+var profiles = buildProfiles()
+
+func buildProfiles() map[string]profile {
+	counts := map[string]map[string]int{}
+	vocab := map[string]struct{}{}
+	for lang, text := range samples {
+		c := map[string]int{}
+		trigrams(stopwords[lang]+" "+text, func(g string) {
+			c[g]++
+			vocab[g] = struct{}{}
+		})
+		counts[lang] = c
+	}
+	out := map[string]profile{}
+	for lang, c := range counts {
+		total := 0
+		for _, n := range c {
+			total += n
+		}
+		// add-half smoothing over the shared vocabulary
+		denom := float64(total) + 0.5*float64(len(vocab))
+		p := profile{logp: make(map[string]float64, len(c)), unseen: math.Log(0.5 / denom)}
+		for g, n := range c {
+			p.logp[g] = math.Log((float64(n) + 0.5) / denom)
+		}
+		out[lang] = p
+	}
+	return out
+}
+
+// trigrams calls fn with every letter trigram of the lowercased words in
+// text, padded with '_' at word boundaries.
+func trigrams(text string, fn func(string)) {
+	for _, w := range words(text) {
+		r := []rune("_" + w + "_")
+		for i := 0; i+3 <= len(r); i++ {
+			fn(string(r[i : i+3]))
+		}
+	}
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' })
+}
+
+// Detect returns the language of text. Short or mixed text yields a low
+// confidence, and no language at all below minConfidence.
+func Detect(text string) Result {
+	if r := []rune(text); len(r) > maxRunes {
+		text = string(r[:maxRunes])
+	}
+	lang, share, letters := script(text)
+	if letters < 8 {
+		return Result{}
+	}
+	switch lang {
+	case "latin":
+		return closest(text, []string{"en", "de", "fr", "es", "it", "pt", "nl", "sv", "pl", "tr"}, share)
+	case "":
+		return Result{}
+	}
+	return Result{Lang: lang, Confidence: round(share)}
+}
+
+// minConfidence is the least confidence reported with a language.
+const minConfidence = 0.2
+
+// closest scores text against the candidate profiles. The confidence grows
+// with the lead over the runner-up, which also grows with text length.
+func closest(text string, langs []string, share float64) Result {
+	type score struct {
+		lang string
+		ll   float64
+	}
+	scores := make([]score, 0, len(langs))
+	for _, l := range langs {
+		p := profiles[l]
+		ll := 0.0
+		trigrams(text, func(g string) {
+			if v, ok := p.logp[g]; ok {
+				ll += v
+			} else {
+				ll += p.unseen
+			}
+		})
+		scores = append(scores, score{l, ll})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].ll > scores[j].ll })
+	lead := scores[0].ll - scores[1].ll
+	conf := (1 - math.Exp(-lead/20)) * share
+	if conf < minConfidence {
+		return Result{Confidence: round(conf)}
+	}
+	return Result{Lang: scores[0].lang, Confidence: round(conf)}
+}
+
+// script returns the language implied by the dominant script of text's
+// letters ("latin" when that needs n-grams), the dominant script's share
+// of the letters, and the number of letters.
+func script(text string) (lang string, share float64, letters int) {
+	counts := map[string]int{}
+	kana, ukr := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			counts["latin"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["cyrillic"]++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukr++
+			}
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			counts["cjk"]++
+			kana++
+		case unicode.Is(unicode.Han, r):
+			counts["cjk"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		}
+	}
+	if letters == 0 {
+		return "", 0, 0
+	}
+	best := ""
+	for s, n := range counts {
+		if best == "" || n > counts[best] || (n == counts[best] && s < best) {
+			best = s
+		}
+	}
+	share = float64(counts[best]) / float64(letters)
+	switch best {
+	case "cyrillic":
+		if ukr*50 > counts[best] { // і, ї, є, ґ are Ukrainian, and common there
+			return "uk", share, letters
+		}
+		return "ru", share, letters
+	case "cjk":
+		if kana*10 > counts[best] {
+			return "ja", share, letters
+		}
+		return "zh", share, letters
+	}
+	return best, share, letters
+}
+
+func round(f float64) float64 { return math.Round(f*100) / 100 }
+
+// Stopwords returns the common words of lang that carry no topic, or nil
+// for languages without a list.
+func Stopwords(lang string) map[string]struct{} {
+	return stopwordSets[lang]
+}
+
+var stopwordSets = func() map[string]map[string]struct{} {
+	out := map[string]map[string]struct{}{}
+	for lang, list := range stopwords {
+		set := map[string]struct{}{}
+		for _, w := range strings.Fields(list) {
+			set[w] = struct{}{}
+		}
+		out[lang] = set
+	}
+	return out
+}()
+
+// Primary returns the primary subtag of a language tag or locale, so
+// "en-GB" and "en_US" are both "en".
+func Primary(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,25 @@
+package langid
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"en": "The city council approved the new budget on Tuesday after a long debate about schools and roads.",
+		"de": "Der Stadtrat hat am Dienstag nach einer langen Debatte über Schulen und Straßen den neuen Haushalt beschlossen.",
+		"fr": "Le conseil municipal a approuvé mardi le nouveau budget après un long débat sur les écoles et les routes.",
+		"es": "El ayuntamiento aprobó el martes el nuevo presupuesto tras un largo debate sobre las escuelas y las carreteras.",
+		"ru": "Городской совет во вторник утвердил новый бюджет после долгих споров о школах и дорогах.",
+		"ja": "市議会は火曜日、学校と道路についての長い議論の末、新しい予算を承認した。",
+	}
+	for want, text := range cases {
+		if got := Detect(text); got.Lang != want || got.Confidence < minConfidence {
+			t.Errorf("%s: got %+v", want, got)
+		}
+	}
+	if got := Detect("OK 42"); got.Lang != "" {
+		t.Errorf("want no language for short text, got %+v", got)
+	}
+	if Primary("en_US") != "en" || Primary(" pt-BR") != "pt" {
+		t.Errorf("unexpected primary subtags")
+	}
+}
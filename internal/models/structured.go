@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Entity is one schema.org item found in JSON-LD, Microdata or RDFa.
+// Property values are strings, numbers, bools, nested Entity values, or
+// []any when a property has several.
+type Entity struct {
+	Type       []string       `json:"type,omitempty"` // schema.org names, e.g. "Product"
+	ID         string         `json:"id,omitempty"`
+	Source     string         `json:"source"` // "json-ld", "microdata" or "rdfa"
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+// Is reports whether e has type t.
+func (e Entity) Is(t string) bool {
+	for _, et := range e.Type {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns every value of prop.
+func (e Entity) Values(prop string) []any {
+	switch v := e.Properties[prop].(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// Text returns the first value of prop as a string. For a nested entity
+// that is its name, falling back to its ID.
+func (e Entity) Text(prop string) string {
+	for _, v := range e.Values(prop) {
+		if s := valueText(v); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Entities returns the nested entities among prop's values.
+func (e Entity) Entities(prop string) []Entity {
+	var out []Entity
+	for _, v := range e.Values(prop) {
+		if c, ok := v.(Entity); ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func valueText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case Entity:
+		if s := v.Text("name"); s != "" {
+			return s
+		}
+		return v.ID
+	}
+	return ""
+}
+
+// Structured is a page's structured data with the common types decoded.
+type Structured struct {
+	Entities    []Entity     `json:"entities,omitempty"`
+	Product     *Product     `json:"product,omitempty"`
+	Article     *Article     `json:"article,omitempty"`
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+type Product struct {
+	Name         string  `json:"name,omitempty"`
+	SKU          string  `json:"sku,omitempty"`
+	Brand        string  `json:"brand,omitempty"`
+	Price        string  `json:"price,omitempty"` // as published, e.g. "19.99"
+	Currency     string  `json:"currency,omitempty"`
+	Availability string  `json:"availability,omitempty"` // e.g. "InStock"
+	Rating       float64 `json:"rating,omitempty"`
+	ReviewCount  int     `json:"reviewCount,omitempty"`
+}
+
+// Article covers Article and its subtypes such as NewsArticle and BlogPosting.
+type Article struct {
+	Type          string   `json:"type"`
+	Source        string   `json:"source"` // of the entity: "json-ld", "microdata" or "rdfa"
+	Headline      string   `json:"headline,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	DatePublished string   `json:"datePublished,omitempty"`
+	DateModified  string   `json:"dateModified,omitempty"`
+}
+
+type Breadcrumb struct {
+	Position int    `json:"position"`
+	Name     string `json:"name,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
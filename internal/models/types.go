@@ -1,26 +1,100 @@
-
 package models
 
 type Meta struct {
-	Title       string            `json:"title,omitempty"`
-	Description string            `json:"description,omitempty"`
-	Keywords    []string          `json:"keywords,omitempty"`
-	OG          map[string]string `json:"og,omitempty"`
-	Canonical   string            `json:"canonical,omitempty"`
-	H1          string            `json:"h1,omitempty"`
-	H2          []string          `json:"h2,omitempty"`
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Keywords    []string            `json:"keywords,omitempty"`
+	OG          map[string]string   `json:"og,omitempty"`
+	Canonical   string              `json:"canonical,omitempty"`
+	H1          string              `json:"h1,omitempty"`
+	H2          []string            `json:"h2,omitempty"`
+	Twitter     *TwitterCard        `json:"twitter,omitempty"`
+	Hreflang    []Alternate         `json:"hreflang,omitempty"`
+	Robots      map[string][]string `json:"robots,omitempty"` // meta name ("robots", "googlebot", ...) -> directives
+	AMPURL      string              `json:"ampUrl,omitempty"`
+	Feeds       []Feed              `json:"feeds,omitempty"`
+	Favicon     string              `json:"favicon,omitempty"`
+	Prev        string              `json:"prev,omitempty"`
+	Next        string              `json:"next,omitempty"`
+	PublishedAt *DateInfo           `json:"publishedAt,omitempty"`
+	ModifiedAt  *DateInfo           `json:"modifiedAt,omitempty"`
+	Authors     []Author            `json:"authors,omitempty"`
+}
+
+// DateInfo is a date found on the page, with where it was found and how
+// far to trust it (0..1).
+type DateInfo struct {
+	Value      string  `json:"value"` // RFC3339
+	Source     string  `json:"source"`
+	Confidence float64 `json:"confidence"`
+}
+
+type Author struct {
+	Name       string  `json:"name"`
+	Source     string  `json:"source"`
+	Confidence float64 `json:"confidence"`
+}
+
+type TwitterCard struct {
+	Card        string `json:"card,omitempty"`
+	Site        string `json:"site,omitempty"`
+	Creator     string `json:"creator,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	ImageAlt    string `json:"imageAlt,omitempty"`
+}
+
+// Alternate is a <link rel="alternate" hreflang> entry.
+type Alternate struct {
+	Lang string `json:"lang"` // e.g. "en-gb" or "x-default"
+	URL  string `json:"url"`
+}
+
+type Feed struct {
+	URL   string `json:"url"`
+	Type  string `json:"type"` // "rss", "atom" or "json"
+	Title string `json:"title,omitempty"`
+}
+
+// Indexing is the effective result of robots meta tags and X-Robots-Tag.
+type Indexing struct {
+	NoIndex  bool     `json:"noindex"`
+	NoFollow bool     `json:"nofollow"`
+	Sources  []string `json:"sources,omitempty"` // where the directives came from, e.g. "meta:robots"
 }
 
 type Content struct {
-	Text      string   `json:"text,omitempty"`
-	WordCount int      `json:"wordCount,omitempty"`
-	Language  string   `json:"language,omitempty"`
-	Headings  []string `json:"headings,omitempty"`
+	Text               string   `json:"text,omitempty"`
+	Extraction         string   `json:"extraction,omitempty"` // "main" (paragraphs separated by blank lines) or "all"
+	WordCount          int      `json:"wordCount,omitempty"`
+	Language           string   `json:"language,omitempty"` // detected from the text, else the declared one
+	LanguageConfidence float64  `json:"languageConfidence,omitempty"`
+	DeclaredLanguage   string   `json:"declaredLanguage,omitempty"` // <html lang> or og:locale
+	LanguageMismatch   bool     `json:"languageMismatch,omitempty"`
+	Headings           []string `json:"headings,omitempty"`
+}
+
+type Link struct {
+	URL      string   `json:"url"`
+	Text     string   `json:"text,omitempty"`
+	Rel      []string `json:"rel,omitempty"`
+	Internal bool     `json:"internal"`
+	Region   string   `json:"region,omitempty"`
+}
+
+type LinkStats struct {
+	Total    int `json:"total"`
+	Internal int `json:"internal"`
+	External int `json:"external"`
+	Nofollow int `json:"nofollow"`
 }
 
 type Page struct {
-	Meta    Meta    `json:"meta"`
-	Content Content `json:"content"`
+	Meta       Meta       `json:"meta"`
+	Content    Content    `json:"content"`
+	Links      []Link     `json:"links,omitempty"`
+	Structured Structured `json:"structured"`
 }
 
 type Classification struct {
@@ -28,11 +102,37 @@ type Classification struct {
 	Reason map[string]string `json:"reason,omitempty"`
 }
 
+type Redirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location,omitempty"`
+}
+
+// HTTPInfo is the response metadata captured by the fetcher.
+type HTTPInfo struct {
+	Status        int                 `json:"status"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	ContentLength int64               `json:"contentLength"`
+	ContentType   string              `json:"contentType,omitempty"`
+	Charset       string              `json:"charset,omitempty"`
+	Redirects     []Redirect          `json:"redirects,omitempty"`
+	RemoteIP      string              `json:"remoteIp,omitempty"`
+}
+
 type CrawlResult struct {
-	SourceURL string         `json:"sourceUrl"`
-	FetchMs   int64          `json:"fetchMs"`
-	Meta      Meta           `json:"meta"`
-	Content   Content        `json:"content"`
-	Class     Classification `json:"class"`
-	Topics    []string       `json:"topics"`
+	SourceURL             string         `json:"sourceUrl"`
+	NormalizedURL         string         `json:"normalizedUrl"`
+	FetchMs               int64          `json:"fetchMs"`
+	HTTP                  HTTPInfo       `json:"http"`
+	NotModified           bool           `json:"notModified,omitempty"`
+	Truncated             bool           `json:"truncated,omitempty"`
+	OriginalContentLength int64          `json:"originalContentLength,omitempty"` // decoded size; omitted if unknown or sent compressed
+	Meta                  Meta           `json:"meta"`
+	Content               Content        `json:"content"`
+	Class                 Classification `json:"class"`
+	Topics                []string       `json:"topics"`
+	Indexing              Indexing       `json:"indexing"`
+	LinkStats             LinkStats      `json:"linkStats"`
+	Links                 []Link         `json:"links,omitempty"`
+	Structured            *Structured    `json:"structured,omitempty"`
 }
@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"brightedge-go-crawler/internal/models"
+)
+
+// Confidence of each source, highest first. Structured data and article:*
+// tags are written for machines; <time>, bylines and URL paths are guesses.
+const (
+	confStructured = 0.95
+	confArticleTag = 0.9
+	confMetaTag    = 0.8
+	confRelAuthor  = 0.7
+	confTimeTagged = 0.7
+	confByline     = 0.6
+	confTimeModif  = 0.6
+	confTime       = 0.5
+	confBylineText = 0.5
+	confURL        = 0.4
+)
+
+var (
+	publishedMeta = map[string]float64{
+		"article:published_time": confArticleTag, "og:published_time": confArticleTag,
+		"date": confMetaTag, "pubdate": confMetaTag, "publishdate": confMetaTag, "publish-date": confMetaTag,
+		"publish_date": confMetaTag, "dc.date.issued": confMetaTag, "dcterms.issued": confMetaTag,
+		"dc.date": confMetaTag, "parsely-pub-date": confMetaTag, "sailthru.date": confMetaTag,
+	}
+	modifiedMeta = map[string]float64{
+		"article:modified_time": confArticleTag, "og:updated_time": confArticleTag,
+		"dcterms.modified": confMetaTag, "dc.date.modified": confMetaTag, "last-modified": confMetaTag,
+	}
+	authorMeta = map[string]float64{
+		"author": confMetaTag, "article:author": confMetaTag, "parsely-author": confMetaTag,
+		"sailthru.author": confMetaTag, "dc.creator": confMetaTag, "dcterms.creator": confMetaTag,
+	}
+)
+
+var (
+	urlDateRe    = regexp.MustCompile(`/((?:19|20)\d{2})[/-]?(0[1-9]|1[0-2])[/-]?(0[1-9]|[12]\d|3[01])(?:/|-|\.|$)`)
+	bylineTextRe = regexp.MustCompile(`^(?i:by|written by|posted by)\s+(.+)$`)
+	bylineCutRe  = regexp.MustCompile(`(?i)\s*(?:\||•|·|—| - |\bupdated\b|\bpublished\b|\bon\b\s+\w+ \d).*$`)
+	nameRe       = regexp.MustCompile(`^\p{Lu}[\p{L}'’.-]*(?:\s+(?:\p{Lu}[\p{L}'’.-]*|van|von|de|der|den|da|di|du|la|le|del|bin|al))*$`)
+	authorSepRe  = regexp.MustCompile(`\s*(?:,|&|\band\b)\s*`)
+)
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.000Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// parseDate reads the date formats seen in the wild. Values without a zone
+// are taken as UTC.
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			if t.Year() < 1990 || t.After(time.Now().AddDate(0, 0, 2)) {
+				return time.Time{}, false
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// attribution collects candidates for one field and keeps the most
+// trusted; on a tie the first found wins.
+type attribution struct {
+	best *models.DateInfo
+}
+
+func (a *attribution) add(value, source string, conf float64) {
+	if a.best != nil && a.best.Confidence >= conf {
+		return
+	}
+	if t, ok := parseDate(value); ok {
+		a.best = &models.DateInfo{Value: t.Format(time.RFC3339), Source: source, Confidence: conf}
+	}
+}
+
+type authorSet struct {
+	best []models.Author
+}
+
+// add offers names found by one source. The most trusted source supplies
+// every author.
+func (s *authorSet) add(names []string, source string, conf float64) {
+	if len(s.best) > 0 {
+		switch top := s.best[0]; {
+		case top.Confidence > conf || (top.Confidence == conf && top.Source != source):
+			return
+		case top.Confidence < conf:
+			s.best = nil
+		}
+	}
+	for _, n := range names {
+		n = strings.TrimSpace(whitespaceRe.ReplaceAllString(n, " "))
+		if n == "" || len(n) > 100 || strings.Contains(n, "://") {
+			continue
+		}
+		dup := false
+		for _, a := range s.best {
+			dup = dup || strings.EqualFold(a.Name, n)
+		}
+		if !dup {
+			s.best = append(s.best, models.Author{Name: n, Source: source, Confidence: conf})
+		}
+	}
+}
+
+// extractAttribution fills in the publish and modified dates and the
+// authors, from structured data, meta tags, <time>, bylines and the URL.
+func extractAttribution(doc *goquery.Document, pageURL string, st models.Structured, m *models.Meta) {
+	var published, modified attribution
+	var authors authorSet
+
+	if a := st.Article; a != nil {
+		published.add(a.DatePublished, a.Source, confStructured)
+		modified.add(a.DateModified, a.Source, confStructured)
+		authors.add(a.Authors, a.Source, confStructured)
+	}
+
+	doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		key := strings.ToLower(strings.TrimSpace(s.AttrOr("property", s.AttrOr("name", s.AttrOr("itemprop", "")))))
+		content := s.AttrOr("content", "")
+		if conf, ok := publishedMeta[key]; ok {
+			published.add(content, "meta:"+key, conf)
+		}
+		if conf, ok := modifiedMeta[key]; ok {
+			modified.add(content, "meta:"+key, conf)
+		}
+		if conf, ok := authorMeta[key]; ok {
+			authors.add(authorSepRe.Split(content, -1), "meta:"+key, conf)
+		}
+	})
+
+	doc.Find("time[datetime]").Each(func(i int, s *goquery.Selection) {
+		dt := s.AttrOr("datetime", "")
+		hint := strings.ToLower(s.AttrOr("itemprop", "") + " " + s.AttrOr("class", "") + " " + s.Parent().AttrOr("class", ""))
+		_, pubdate := s.Attr("pubdate")
+		switch {
+		case strings.Contains(hint, "modif") || strings.Contains(hint, "updat"):
+			modified.add(dt, "time", confTimeModif)
+		case pubdate || strings.Contains(hint, "publish") || strings.Contains(hint, "date"):
+			published.add(dt, "time", confTimeTagged)
+		default:
+			published.add(dt, "time", confTime)
+		}
+	})
+
+	doc.Find(`a[rel~="author"]`).Each(func(i int, s *goquery.Selection) {
+		authors.add(bylineNames(s.Text()), "byline", confRelAuthor)
+	})
+	doc.Find(`[class*="byline"],[class*="author"]`).Each(func(i int, s *goquery.Selection) {
+		authors.add(bylineNames(s.Text()), "byline", confByline)
+	})
+	doc.Find("p,div,span").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if s.Children().Length() > 2 {
+			return true
+		}
+		t := strings.TrimSpace(whitespaceRe.ReplaceAllString(s.Text(), " "))
+		if len(t) <= 120 && bylineTextRe.MatchString(t) {
+			authors.add(bylineNames(t), "byline-text", confBylineText)
+			return false
+		}
+		return i < 200
+	})
+
+	if mt := urlDateRe.FindStringSubmatch(pageURL); mt != nil {
+		published.add(mt[1]+"-"+mt[2]+"-"+mt[3], "url", confURL)
+	}
+
+	m.PublishedAt, m.ModifiedAt, m.Authors = published.best, modified.best, authors.best
+}
+
+// bylineNames returns the person names in a byline such as
+// "By Jane Roe and John Doe | May 1, 2024".
+func bylineNames(s string) []string {
+	s = strings.TrimSpace(whitespaceRe.ReplaceAllString(s, " "))
+	if len(s) > 120 {
+		return nil
+	}
+	if mt := bylineTextRe.FindStringSubmatch(s); mt != nil {
+		s = mt[1]
+	}
+	s = bylineCutRe.ReplaceAllString(s, "")
+	var names []string
+	for _, n := range authorSepRe.Split(s, -1) {
+		n = strings.TrimSpace(n)
+		if w := len(strings.Fields(n)); w >= 1 && w <= 5 && nameRe.MatchString(n) {
+			names = append(names, n)
+		}
+	}
+	return names
+}
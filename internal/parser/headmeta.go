@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"brightedge-go-crawler/internal/models"
+)
+
+// extractHeadMeta fills in Twitter card, hreflang, robots and <link>
+// relations. URLs are resolved against base.
+func extractHeadMeta(doc *goquery.Document, base *url.URL, m *models.Meta) {
+	tw := models.TwitterCard{}
+	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		name := strings.ToLower(strings.TrimSpace(s.AttrOr("name", s.AttrOr("property", ""))))
+		content := strings.TrimSpace(s.AttrOr("content", s.AttrOr("value", "")))
+		if name == "" || content == "" {
+			return
+		}
+		switch name {
+		case "twitter:card":
+			tw.Card = content
+		case "twitter:site":
+			tw.Site = content
+		case "twitter:creator":
+			tw.Creator = content
+		case "twitter:title":
+			tw.Title = content
+		case "twitter:description":
+			tw.Description = content
+		case "twitter:image", "twitter:image:src":
+			tw.Image = resolve(base, content)
+		case "twitter:image:alt":
+			tw.ImageAlt = content
+		}
+		if _, isName := s.Attr("name"); isName && (name == "robots" || strings.Contains(name, "bot")) {
+			if m.Robots == nil {
+				m.Robots = map[string][]string{}
+			}
+			m.Robots[name] = append(m.Robots[name], directives(content)...)
+		}
+	})
+	if tw != (models.TwitterCard{}) {
+		m.Twitter = &tw
+	}
+
+	doc.Find("link[href]").Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" {
+			return
+		}
+		href = resolve(base, href)
+		rel := strings.Fields(strings.ToLower(s.AttrOr("rel", "")))
+		switch {
+		case has(rel, "alternate") && s.AttrOr("hreflang", "") != "":
+			m.Hreflang = append(m.Hreflang, models.Alternate{Lang: strings.ToLower(strings.TrimSpace(s.AttrOr("hreflang", ""))), URL: href})
+		case has(rel, "alternate"):
+			if typ := feedType(s.AttrOr("type", "")); typ != "" {
+				m.Feeds = append(m.Feeds, models.Feed{URL: href, Type: typ, Title: strings.TrimSpace(s.AttrOr("title", ""))})
+			}
+		case has(rel, "amphtml"):
+			if m.AMPURL == "" {
+				m.AMPURL = href
+			}
+		case has(rel, "icon"):
+			m.Favicon = href // rel="icon" wins over apple-touch-icon
+		case has(rel, "apple-touch-icon"):
+			if m.Favicon == "" {
+				m.Favicon = href
+			}
+		case has(rel, "prev") || has(rel, "previous"):
+			if m.Prev == "" {
+				m.Prev = href
+			}
+		case has(rel, "next"):
+			if m.Next == "" {
+				m.Next = href
+			}
+		}
+	})
+}
+
+func feedType(mediaType string) string {
+	mt, _, _ := strings.Cut(strings.ToLower(mediaType), ";")
+	switch strings.TrimSpace(mt) {
+	case "application/rss+xml":
+		return "rss"
+	case "application/atom+xml":
+		return "atom"
+	case "application/feed+json", "application/json":
+		return "json"
+	}
+	return ""
+}
+
+func directives(s string) []string {
+	var out []string
+	for _, d := range strings.Split(s, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func has(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Indexing combines the page's robots meta tags with the response's
+// X-Robots-Tag values the way a search engine would: directives for all
+// robots and those for googlebot apply, others are ignored.
+func Indexing(m models.Meta, xRobotsTag []string) models.Indexing {
+	var ix models.Indexing
+	apply := func(source string, ds []string) {
+		hit := false
+		for _, d := range ds {
+			switch d {
+			case "noindex":
+				ix.NoIndex, hit = true, true
+			case "nofollow":
+				ix.NoFollow, hit = true, true
+			case "none":
+				ix.NoIndex, ix.NoFollow, hit = true, true, true
+			}
+		}
+		if hit && !has(ix.Sources, source) {
+			ix.Sources = append(ix.Sources, source)
+		}
+	}
+	for _, name := range []string{"robots", "googlebot"} {
+		apply("meta:"+name, m.Robots[name])
+	}
+	for _, v := range xRobotsTag {
+		agent, rest := "", v
+		// "googlebot: noindex" targets one robot; "unavailable_after: ..." is a directive
+		if before, after, ok := strings.Cut(v, ":"); ok && !strings.Contains(before, ",") {
+			switch b := strings.ToLower(strings.TrimSpace(before)); b {
+			case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+			default:
+				agent, rest = b, after
+			}
+		}
+		if agent == "" || agent == "googlebot" {
+			apply("x-robots-tag", directives(rest))
+		}
+	}
+	return ix
+}
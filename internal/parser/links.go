@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"brightedge-go-crawler/internal/models"
+)
+
+// regionTags maps landmark elements and ARIA roles to the region reported on a link.
+var regionTags = map[string]string{
+	"nav":     "nav",
+	"header":  "header",
+	"footer":  "footer",
+	"aside":   "aside",
+	"main":    "main",
+	"article": "main",
+}
+
+var regionRoles = map[string]string{
+	"navigation":    "nav",
+	"banner":        "header",
+	"contentinfo":   "footer",
+	"complementary": "aside",
+	"main":          "main",
+}
+
+// extractLinks returns every <a href> resolved against <base href> (or
+// pageURL). Non-http(s) links such as mailto: and javascript: are skipped.
+func extractLinks(doc *goquery.Document, pageURL string) []models.Link {
+	page, _ := url.Parse(pageURL)
+	base := baseURL(doc, page)
+
+	var links []models.Link
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") {
+			return
+		}
+		u, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return
+		}
+		u.Fragment = ""
+
+		link := models.Link{
+			URL:    u.String(),
+			Text:   strings.TrimSpace(whitespaceRe.ReplaceAllString(s.Text(), " ")),
+			Region: region(s),
+		}
+		if link.Text == "" {
+			link.Text = strings.TrimSpace(s.Find("img[alt]").First().AttrOr("alt", ""))
+		}
+		if rel := strings.Fields(strings.ToLower(s.AttrOr("rel", ""))); len(rel) > 0 {
+			link.Rel = rel
+		}
+		link.Internal = page != nil && sameSite(page.Hostname(), u.Hostname())
+		links = append(links, link)
+	})
+	return links
+}
+
+// baseURL returns <base href> resolved against page, or page itself.
+func baseURL(doc *goquery.Document, page *url.URL) *url.URL {
+	href, ok := doc.Find("base[href]").First().Attr("href")
+	if !ok {
+		return page
+	}
+	b, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return page
+	}
+	if page != nil {
+		b = page.ResolveReference(b)
+	}
+	return b
+}
+
+// region returns the nearest landmark around s, or "" for plain body content.
+func region(s *goquery.Selection) string {
+	for p := s.Parent(); p.Length() > 0; p = p.Parent() {
+		if r, ok := regionRoles[strings.ToLower(p.AttrOr("role", ""))]; ok {
+			return r
+		}
+		if r, ok := regionTags[goquery.NodeName(p)]; ok {
+			return r
+		}
+	}
+	return ""
+}
+
+func sameSite(a, b string) bool {
+	a = strings.TrimPrefix(strings.ToLower(a), "www.")
+	b = strings.TrimPrefix(strings.ToLower(b), "www.")
+	return a == b
+}
@@ -4,6 +4,7 @@ package parser
 import (
 	"bytes"
 	"io"
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -11,19 +12,48 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/html/charset"
 
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/langid"
 	"brightedge-go-crawler/internal/models"
 )
 
-type Parser struct{}
+type Parser struct {
+	mode ContentMode
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
 
-func New() *Parser { return &Parser{} }
+// WithContentMode sets how Content.Text is extracted (ContentMain by default).
+func WithContentMode(m ContentMode) Option {
+	return func(p *Parser) { p.mode = m }
+}
+
+func New(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
 
 var whitespaceRe = regexp.MustCompile(`\s+`)
 
 func (p *Parser) Extract(r io.Reader, contentType string) (models.Page, error) {
+	return p.ExtractURL(r, contentType, "")
+}
+
+// ExtractURL is Extract for a page fetched from pageURL, which is needed to
+// resolve relative links and tell internal from external ones.
+func (p *Parser) ExtractURL(r io.Reader, contentType, pageURL string) (models.Page, error) {
 	// Decode to UTF-8 if needed
 	buf := new(bytes.Buffer)
-	_, _ = io.Copy(buf, r)
+	if _, err := io.Copy(buf, r); err != nil {
+		if fe := fetcherr.Classify(err); fe.Code != fetcherr.CodeUnknown {
+			return models.Page{}, fe
+		}
+		return models.Page{}, fetcherr.New(fetcherr.CodeDecode, err)
+	}
 	data := buf.Bytes()
 
 	enc, _, _ := charset.DetermineEncoding(data, contentType)
@@ -31,16 +61,18 @@ func (p *Parser) Extract(r io.Reader, contentType string) (models.Page, error) {
 	if err != nil {
 		// fallback: if already utf-8, continue
 		if !utf8.Valid(data) {
-			return models.Page{}, err
+			return models.Page{}, fetcherr.New(fetcherr.CodeDecode, err)
 		}
 		utf8data = data
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(utf8data))
 	if err != nil {
-		return models.Page{}, err
+		return models.Page{}, fetcherr.New(fetcherr.CodeDecode, err)
 	}
 
+	ldBlocks := jsonLDBlocks(doc)
+
 	// Remove script & style
 	doc.Find("script,noscript,style").Each(func(i int, s *goquery.Selection) {
 		s.Remove()
@@ -85,30 +117,34 @@ func (p *Parser) Extract(r io.Reader, contentType string) (models.Page, error) {
 		}
 	})
 
-	// main text: gather paragraphs and list items
-	var parts []string
-	doc.Find("p,li").Each(func(i int, s *goquery.Selection) {
-		t := strings.TrimSpace(s.Text())
-		if t != "" {
-			parts = append(parts, t)
+	// main text: the main content block, one paragraph per line pair, or
+	// every paragraph and list item
+	text, extraction := allText(doc), "all"
+	if p.mode == ContentMain {
+		if paras := mainContent(doc.Nodes[0]); len(strings.Fields(strings.Join(paras, " "))) >= minMainWords {
+			text, extraction = strings.Join(paras, "\n\n"), "main"
 		}
-	})
-	text := strings.TrimSpace(whitespaceRe.ReplaceAllString(strings.Join(parts, " "), " "))
-	wordCount := 0
-	if text != "" {
-		wordCount = len(strings.Fields(text))
 	}
+	wordCount := len(strings.Fields(text))
 
-	// language detection (very light heuristic using <html lang> or og:locale)
-	lang := strings.TrimSpace(doc.Find("html").AttrOr("lang", ""))
-	if lang == "" {
-		lang = og["og:locale"]
+	// language: detected from the text, checked against <html lang> or og:locale
+	declared := strings.TrimSpace(doc.Find("html").AttrOr("lang", ""))
+	if declared == "" {
+		declared = og["og:locale"]
 	}
+	detected := langid.Detect(title + "\n" + text)
 
 	content := models.Content{
-		Text:      text,
-		WordCount: wordCount,
-		Language:  lang,
+		Text:               text,
+		Extraction:         extraction,
+		WordCount:          wordCount,
+		Language:           declared,
+		LanguageConfidence: detected.Confidence,
+		DeclaredLanguage:   declared,
+	}
+	if detected.Lang != "" {
+		content.Language = detected.Lang
+		content.LanguageMismatch = declared != "" && langid.Primary(declared) != detected.Lang
 	}
 	// collect headings too
 	doc.Find("h1,h2,h3").Each(func(i int, s *goquery.Selection) {
@@ -128,5 +164,27 @@ func (p *Parser) Extract(r io.Reader, contentType string) (models.Page, error) {
 		H2:          h2s,
 	}
 
-	return models.Page{Meta: meta, Content: content}, nil
+	page, _ := url.Parse(pageURL)
+	base := baseURL(doc, page)
+	extractHeadMeta(doc, base, &meta)
+	structured := extractStructured(doc, ldBlocks, base)
+	extractAttribution(doc, pageURL, structured, &meta)
+	return models.Page{
+		Meta:       meta,
+		Content:    content,
+		Links:      extractLinks(doc, pageURL),
+		Structured: structured,
+	}, nil
+}
+
+// allText joins the text of every <p> and <li>, menus and footers included.
+func allText(doc *goquery.Document) string {
+	var parts []string
+	doc.Find("p,li").Each(func(i int, s *goquery.Selection) {
+		t := strings.TrimSpace(s.Text())
+		if t != "" {
+			parts = append(parts, t)
+		}
+	})
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(strings.Join(parts, " "), " "))
 }
@@ -4,6 +4,8 @@ package parser
 import (
 	"strings"
 	"testing"
+
+	"brightedge-go-crawler/internal/models"
 )
 
 const sampleHTML = `<!doctype html><html lang="en"><head>
@@ -31,4 +33,206 @@ func TestExtract(t *testing.T) {
 	if page.Meta.OG["og:type"] != "article" {
 		t.Fatal("og:type missing")
 	}
+
+	const german = `<html lang="en"><body><p>Der Stadtrat hat am Dienstag nach einer langen Debatte
+über Schulen und Straßen den neuen Haushalt beschlossen.</p></body></html>`
+	page, err = p.Extract(strings.NewReader(german), "text/html")
+	if err != nil {
+		t.Fatalf("extract error: %v", err)
+	}
+	if c := page.Content; c.Language != "de" || c.DeclaredLanguage != "en" || !c.LanguageMismatch {
+		t.Fatalf("want German detected against declared en, got %+v", c)
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	const html = `<html><head><base href="/docs/"></head><body>
+<nav><a href="intro">Intro</a></nav>
+<main><a href="https://www.example.com/about#team" rel="NoFollow ugc">About</a>
+<a href="https://other.org/x"><img alt="Other"></a>
+<a href="mailto:me@example.com">mail</a><a href="#top">top</a></main>
+<div role="contentinfo"><a href="/legal">Legal</a></div>
+</body></html>`
+	page, err := New().ExtractURL(strings.NewReader(html), "text/html", "https://example.com/start")
+	if err != nil {
+		t.Fatalf("extract error: %v", err)
+	}
+	if len(page.Links) != 4 {
+		t.Fatalf("want 4 links, got %d: %+v", len(page.Links), page.Links)
+	}
+	want := []struct {
+		url, text, region string
+		internal          bool
+	}{
+		{"https://example.com/docs/intro", "Intro", "nav", true},
+		{"https://www.example.com/about", "About", "main", true},
+		{"https://other.org/x", "Other", "main", false},
+		{"https://example.com/legal", "Legal", "footer", true},
+	}
+	for i, w := range want {
+		l := page.Links[i]
+		if l.URL != w.url || l.Text != w.text || l.Region != w.region || l.Internal != w.internal {
+			t.Errorf("link %d: got %+v, want %+v", i, l, w)
+		}
+	}
+	if rel := page.Links[1].Rel; len(rel) != 2 || rel[0] != "nofollow" {
+		t.Errorf("rel not normalized: %v", rel)
+	}
+}
+
+func TestExtractStructured(t *testing.T) {
+	const html = `<html><head>
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+  {"@type":"NewsArticle","headline":"Big news","datePublished":"2024-05-01T10:00:00Z","author":[{"@id":"#jane"},"Bob"]},
+  {"@type":"Person","@id":"#jane","name":"Jane Roe"},
+  {"@type":"BreadcrumbList","itemListElement":[
+    {"@type":"ListItem","position":2,"name":"World","item":"https://example.com/world"},
+    {"@type":"ListItem","position":1,"name":"Home","item":{"@id":"https://example.com/"}}]}]}</script>
+<script type="application/ld+json">not json</script>
+</head><body>
+<div itemscope itemtype="https://schema.org/Product">
+  <h1 itemprop="name">Widget</h1><meta itemprop="sku" content="W-1">
+  <div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+    <span itemprop="priceCurrency" content="USD">$</span><span itemprop="price">19.99</span>
+    <link itemprop="availability" href="https://schema.org/InStock">
+  </div>
+  <div itemprop="aggregateRating" itemscope itemtype="https://schema.org/AggregateRating">
+    <span itemprop="ratingValue">4.5</span> from <span itemprop="reviewCount">12</span>
+  </div>
+</div>
+<p vocab="https://schema.org/" typeof="Person"><span property="name">Ann</span></p>
+</body></html>`
+	page, err := New().ExtractURL(strings.NewReader(html), "text/html", "https://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := page.Structured
+	if len(st.Entities) != 5 {
+		t.Fatalf("want 5 top-level entities, got %+v", st.Entities)
+	}
+	if a := st.Article; a == nil || a.Type != "NewsArticle" || a.Headline != "Big news" ||
+		strings.Join(a.Authors, ",") != "Jane Roe,Bob" {
+		t.Fatalf("unexpected article %+v", a)
+	}
+	if len(st.Breadcrumbs) != 2 || st.Breadcrumbs[0].Name != "Home" || st.Breadcrumbs[0].URL != "https://example.com/" {
+		t.Fatalf("unexpected breadcrumbs %+v", st.Breadcrumbs)
+	}
+	want := models.Product{Name: "Widget", SKU: "W-1", Price: "19.99", Currency: "USD", Availability: "InStock", Rating: 4.5, ReviewCount: 12}
+	if p := st.Product; p == nil || *p != want {
+		t.Fatalf("unexpected product %+v", p)
+	}
+	if p := page.Meta.PublishedAt; p == nil || p.Source != "json-ld" || p.Value != "2024-05-01T10:00:00Z" {
+		t.Fatalf("want publishedAt from json-ld, got %+v", p)
+	}
+	if last := st.Entities[4]; last.Source != "rdfa" || !last.Is("Person") || last.Text("name") != "Ann" {
+		t.Fatalf("unexpected rdfa entity %+v", last)
+	}
+}
+
+func TestExtractHeadMeta(t *testing.T) {
+	const html = `<html><head>
+<meta name="twitter:card" content="summary_large_image"><meta name="twitter:image" content="/i.png">
+<meta name="robots" content="NoIndex, follow"><meta name="googlebot" content="nosnippet">
+<link rel="alternate" hreflang="en-GB" href="/uk/"><link rel="alternate" hreflang="x-default" href="/">
+<link rel="alternate" type="application/rss+xml" title="News" href="/feed.xml">
+<link rel="amphtml" href="/a.amp"><link rel="shortcut icon" href="/favicon.ico">
+<link rel="prev" href="?page=1"><link rel="next" href="?page=3">
+</head><body></body></html>`
+	page, err := New().ExtractURL(strings.NewReader(html), "text/html", "https://example.com/a?page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := page.Meta
+	if m.Twitter == nil || m.Twitter.Card != "summary_large_image" || m.Twitter.Image != "https://example.com/i.png" {
+		t.Fatalf("unexpected twitter card %+v", m.Twitter)
+	}
+	if len(m.Hreflang) != 2 || m.Hreflang[0] != (models.Alternate{Lang: "en-gb", URL: "https://example.com/uk/"}) {
+		t.Fatalf("unexpected hreflang %+v", m.Hreflang)
+	}
+	if len(m.Feeds) != 1 || m.Feeds[0].Type != "rss" || m.AMPURL != "https://example.com/a.amp" ||
+		m.Favicon != "https://example.com/favicon.ico" || m.Next != "https://example.com/a?page=3" {
+		t.Fatalf("unexpected links %+v", m)
+	}
+
+	if ix := Indexing(m, nil); !ix.NoIndex || ix.NoFollow || ix.Sources[0] != "meta:robots" {
+		t.Fatalf("unexpected indexing from meta %+v", ix)
+	}
+	if ix := Indexing(models.Meta{}, []string{"otherbot: none", "unavailable_after: 2030-01-01"}); ix.NoIndex || ix.NoFollow {
+		t.Fatalf("directives for another robot applied: %+v", ix)
+	}
+	if ix := Indexing(models.Meta{}, []string{"googlebot: nofollow", "noindex"}); !ix.NoIndex || !ix.NoFollow {
+		t.Fatalf("unexpected indexing from X-Robots-Tag %+v", ix)
+	}
+}
+
+func TestExtractMainContent(t *testing.T) {
+	para := func(s string) string {
+		return "<p>" + strings.Repeat(s+", and then some more words about it. ", 4) + "</p>"
+	}
+	html := `<html><body>
+<nav><ul><li><a href="/">Home</a></li><li><a href="/world">World news today</a></li></ul></nav>
+<div class="cookie-banner"><p>We use cookies to improve your experience on this site, please accept.</p></div>
+<div id="content"><article>
+  <h1>Rivers rise</h1>` + para("Heavy rain fell across the valley") + para("Officials opened the flood gates") + `
+  <ul class="related-links"><li><a href="/x">Another story about weather and rivers</a></li>
+  <li><a href="/y">Yet another story people read</a></li></ul>
+</article></div>
+<footer><p>Copyright 2024 Example News, all rights reserved worldwide.</p></footer>
+</body></html>`
+
+	page, err := New().Extract(strings.NewReader(html), "text/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := page.Content
+	paras := strings.Split(c.Text, "\n\n")
+	if c.Extraction != "main" || len(paras) != 3 || paras[0] != "Rivers rise" || !strings.HasPrefix(paras[1], "Heavy rain") {
+		t.Fatalf("unexpected main content (%s): %q", c.Extraction, c.Text)
+	}
+	for _, junk := range []string{"cookies", "Copyright", "World news", "Another story"} {
+		if strings.Contains(c.Text, junk) {
+			t.Fatalf("boilerplate %q kept in %q", junk, c.Text)
+		}
+	}
+
+	all, _ := New(WithContentMode(ContentAll)).Extract(strings.NewReader(html), "text/html")
+	if all.Content.Extraction != "all" || !strings.Contains(all.Content.Text, "cookies") {
+		t.Fatalf("all mode should keep every paragraph: %q", all.Content.Text)
+	}
+}
+
+func TestExtractAttribution(t *testing.T) {
+	const head = `<html><head>
+<meta property="article:published_time" content="2024-05-01T10:00:00+02:00">
+<meta name="author" content="Desk Staff">
+</head><body><article>
+<p class="byline">By <a rel="author" href="/jane">Jane Roe</a> and <a rel="author" href="/john">John van Doe</a> | May 1, 2024</p>
+<time class="updated" datetime="2024-05-02">May 2</time>
+</article></body></html>`
+	page, err := New().ExtractURL(strings.NewReader(head), "text/html", "https://example.com/2023/01/15/story")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := page.Meta
+	if p := m.PublishedAt; p == nil || p.Value != "2024-05-01T10:00:00+02:00" || p.Source != "meta:article:published_time" {
+		t.Fatalf("unexpected publishedAt %+v", p)
+	}
+	if md := m.ModifiedAt; md == nil || md.Value != "2024-05-02T00:00:00Z" || md.Source != "time" {
+		t.Fatalf("unexpected modifiedAt %+v", md)
+	}
+	if len(m.Authors) != 1 || m.Authors[0].Name != "Desk Staff" {
+		t.Fatalf("meta author should beat the byline: %+v", m.Authors)
+	}
+
+	// without meta tags: bylines and the URL path
+	body := strings.Replace(head, `<meta name="author" content="Desk Staff">`, "", 1)
+	body = strings.Replace(body, `<meta property="article:published_time" content="2024-05-01T10:00:00+02:00">`, "", 1)
+	page, _ = New().ExtractURL(strings.NewReader(body), "text/html", "https://example.com/2023/01/15/story")
+	m = page.Meta
+	if len(m.Authors) != 2 || m.Authors[1].Name != "John van Doe" || m.Authors[0].Source != "byline" {
+		t.Fatalf("unexpected byline authors %+v", m.Authors)
+	}
+	if p := m.PublishedAt; p == nil || p.Value != "2023-01-15T00:00:00Z" || p.Source != "url" || p.Confidence >= m.Authors[0].Confidence {
+		t.Fatalf("unexpected publishedAt from url %+v", p)
+	}
 }
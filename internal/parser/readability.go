@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ContentMode selects how Content.Text is built.
+type ContentMode int
+
+const (
+	// ContentMain keeps the page's main content block, found by text and
+	// link density, and falls back to ContentAll when none stands out.
+	ContentMain ContentMode = iota
+	// ContentAll joins the text of every <p> and <li>.
+	ContentAll
+)
+
+// ParseContentMode accepts "main" (or "") and "all".
+func ParseContentMode(s string) (ContentMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "main":
+		return ContentMain, nil
+	case "all":
+		return ContentAll, nil
+	}
+	return 0, fmt.Errorf("unknown content mode %q (want main or all)", s)
+}
+
+// minMainWords is the least main content accepted before falling back to
+// all text; below it the "main" block is more likely a teaser or a caption.
+const minMainWords = 50
+
+var (
+	unlikelyRe = regexp.MustCompile(`(?i)ad-break|agegate|banner|breadcrumb|combx|comment|community|consent|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|widget`)
+	maybeRe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|post|entry|story|text`)
+	positiveRe = regexp.MustCompile(`(?i)article|body|content|entry|h-entry|main|page|post|story|text|blog`)
+	negativeRe = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+)
+
+// boilerplateTags never hold main content.
+var boilerplateTags = map[atom.Atom]bool{
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Select: true, atom.Menu: true, atom.Dialog: true, atom.Svg: true,
+	atom.Iframe: true, atom.Template: true,
+}
+
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"dialog": true, "alertdialog": true, "menu": true, "menubar": true, "search": true,
+}
+
+// blockTags are the elements kept as paragraphs of the main content.
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Blockquote: true, atom.Li: true, atom.Td: true,
+	atom.Dd: true, atom.Dt: true, atom.Figcaption: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// containerTags may hold blocks; a container without any is scored like a <p>.
+var containerTags = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true, atom.Table: true,
+	atom.Tbody: true, atom.Tr: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Figure: true,
+}
+
+// mainContent returns the paragraphs of the highest scoring block and its
+// qualifying siblings, in the style of Mozilla's Readability: every
+// paragraph adds to its parent's and, less, its grandparents' scores,
+// which are then weighed by class names and cut by link density.
+func mainContent(root *html.Node) []string {
+	r := &readability{scores: map[*html.Node]float64{}, text: map[*html.Node]string{}}
+	r.score(root)
+
+	var top *html.Node
+	for _, n := range r.candidates {
+		r.scores[n] *= 1 - r.linkDensity(n)
+		if top == nil || r.scores[n] > r.scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		return nil
+	}
+
+	var paras []string
+	parent := top.Parent
+	if parent == nil || top.DataAtom == atom.Body {
+		return r.paragraphs(top, nil)
+	}
+	threshold := max(10, r.scores[top]*0.2)
+	for c := parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || r.boilerplate(c) {
+			continue
+		}
+		keep := c == top || r.scores[c] >= threshold
+		if !keep && c.DataAtom == atom.P {
+			t := r.textOf(c)
+			ld := r.linkDensity(c)
+			keep = (len(t) > 80 && ld < 0.25) || (len(t) > 0 && ld == 0 && strings.HasSuffix(t, "."))
+		}
+		if keep {
+			paras = r.paragraphs(c, paras)
+		}
+	}
+	return paras
+}
+
+type readability struct {
+	scores     map[*html.Node]float64
+	candidates []*html.Node          // scored nodes in the order first seen
+	text       map[*html.Node]string // collapsed text, memoized
+}
+
+func (r *readability) score(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || r.boilerplate(c) {
+			continue
+		}
+		if !r.scorable(c) {
+			r.score(c)
+			continue
+		}
+		t := r.textOf(c)
+		if len(t) < 25 {
+			continue
+		}
+		s := 1 + float64(strings.Count(t, ",")+strings.Count(t, "，")) + min(float64(len(t)/100), 3)
+		level := 0
+		for a := c.Parent; a != nil && level < 3; a, level = a.Parent, level+1 {
+			if a.Type != html.ElementNode {
+				break
+			}
+			if _, ok := r.scores[a]; !ok {
+				r.scores[a] = initialScore(a)
+				r.candidates = append(r.candidates, a)
+			}
+			switch level {
+			case 0:
+				r.scores[a] += s
+			case 1:
+				r.scores[a] += s / 2
+			default:
+				r.scores[a] += s / float64(level*3)
+			}
+		}
+	}
+}
+
+// scorable reports whether n is a paragraph for scoring: a <p>-like block,
+// or a container with text but no blocks of its own.
+func (r *readability) scorable(n *html.Node) bool {
+	switch {
+	case n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote || n.DataAtom == atom.Td:
+		return true
+	case containerTags[n.DataAtom]:
+		return !hasBlocks(n)
+	}
+	return false
+}
+
+func hasBlocks(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (blockTags[c.DataAtom] || containerTags[c.DataAtom] || hasBlocks(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+func initialScore(n *html.Node) float64 {
+	s := classWeight(n)
+	switch n.DataAtom {
+	case atom.Div, atom.Article, atom.Main:
+		s += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		s += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		s -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		s -= 5
+	}
+	return s
+}
+
+func classWeight(n *html.Node) float64 {
+	var w float64
+	for _, v := range []string{attr(n, "class"), attr(n, "id")} {
+		if v == "" {
+			continue
+		}
+		if negativeRe.MatchString(v) {
+			w -= 25
+		}
+		if positiveRe.MatchString(v) {
+			w += 25
+		}
+	}
+	return w
+}
+
+// boilerplate reports whether n's subtree is navigation, chrome or
+// anything else that is never main content.
+func (r *readability) boilerplate(n *html.Node) bool {
+	if boilerplateTags[n.DataAtom] || boilerplateRoles[strings.ToLower(attr(n, "role"))] {
+		return true
+	}
+	if _, hidden := attrOk(n, "hidden"); hidden || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	match := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyRe.MatchString(match) && !maybeRe.MatchString(match)
+}
+
+// paragraphs appends the text of every block under n, skipping
+// boilerplate and link lists such as "related articles".
+func (r *readability) paragraphs(n *html.Node, out []string) []string {
+	if r.boilerplate(n) {
+		return out
+	}
+	if blockTags[n.DataAtom] || (containerTags[n.DataAtom] && !hasBlocks(n)) {
+		if t := r.textOf(n); t != "" && r.linkDensity(n) < 0.5 {
+			out = append(out, t)
+		}
+		return out
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			out = r.paragraphs(c, out)
+		}
+	}
+	return out
+}
+
+func (r *readability) textOf(n *html.Node) string {
+	if t, ok := r.text[n]; ok {
+		return t
+	}
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode, html.DocumentNode:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	t := strings.TrimSpace(whitespaceRe.ReplaceAllString(b.String(), " "))
+	r.text[n] = t
+	return t
+}
+
+// linkDensity is the share of n's text inside links.
+func (r *readability) linkDensity(n *html.Node) float64 {
+	total := len(r.textOf(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.A {
+				linked += len(r.textOf(c))
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linked) / float64(total)
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOk(n, key)
+	return v
+}
+
+func attrOk(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
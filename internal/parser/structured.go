@@ -0,0 +1,391 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"brightedge-go-crawler/internal/models"
+)
+
+// jsonLDBlocks returns the contents of every JSON-LD script. It must run
+// before scripts are stripped from doc.
+func jsonLDBlocks(doc *goquery.Document) []string {
+	var blocks []string
+	doc.Find("script[type]").Each(func(i int, s *goquery.Selection) {
+		typ, _, _ := strings.Cut(s.AttrOr("type", ""), ";")
+		if strings.EqualFold(strings.TrimSpace(typ), "application/ld+json") {
+			blocks = append(blocks, s.Text())
+		}
+	})
+	return blocks
+}
+
+// extractStructured collects JSON-LD, Microdata and RDFa entities and
+// decodes the first Product, Article and BreadcrumbList among them.
+func extractStructured(doc *goquery.Document, ldBlocks []string, base *url.URL) models.Structured {
+	var es []models.Entity
+	for _, b := range ldBlocks {
+		es = append(es, jsonLD(b)...)
+	}
+	es = append(es, microdata.entities(doc, base)...)
+	es = append(es, rdfa.entities(doc, base)...)
+	return decodeStructured(es, base)
+}
+
+func jsonLD(block string) []models.Entity {
+	block = strings.TrimSpace(block)
+	block = strings.TrimSuffix(strings.TrimPrefix(block, "<!--"), "-->")
+	block = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(block), "//<![CDATA["), "//]]>")
+	var v any
+	if err := json.Unmarshal([]byte(block), &v); err != nil {
+		return nil // broken markup is common; it just contributes nothing
+	}
+	return ldTop(v)
+}
+
+// ldTop flattens top-level arrays and @graph containers into entities.
+func ldTop(v any) []models.Entity {
+	switch v := v.(type) {
+	case []any:
+		var out []models.Entity
+		for _, item := range v {
+			out = append(out, ldTop(item)...)
+		}
+		return out
+	case map[string]any:
+		var out []models.Entity
+		if g, ok := v["@graph"]; ok {
+			out = ldTop(g)
+			if _, typed := v["@type"]; !typed {
+				return out
+			}
+		}
+		return append([]models.Entity{ldEntity(v)}, out...)
+	}
+	return nil
+}
+
+func ldEntity(m map[string]any) models.Entity {
+	e := models.Entity{Source: "json-ld", Properties: map[string]any{}}
+	e.ID, _ = m["@id"].(string)
+	switch t := m["@type"].(type) {
+	case string:
+		e.Type = []string{schemaName(t)}
+	case []any:
+		for _, x := range t {
+			if s, ok := x.(string); ok {
+				e.Type = append(e.Type, schemaName(s))
+			}
+		}
+	}
+	for k, v := range m {
+		if strings.HasPrefix(k, "@") {
+			continue
+		}
+		e.Properties[schemaName(k)] = ldValue(v)
+	}
+	return e
+}
+
+func ldValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if val, ok := v["@value"]; ok {
+			return ldValue(val)
+		}
+		return ldEntity(v)
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, x := range v {
+			out = append(out, ldValue(x))
+		}
+		return out
+	}
+	return v
+}
+
+// itemSyntax describes an attribute-based markup: Microdata or RDFa.
+type itemSyntax struct {
+	source string
+	scope  string // attribute that starts an item
+	typ    string // attribute holding its types
+	prop   string // attribute naming a property
+	id     []string
+}
+
+var (
+	microdata = itemSyntax{source: "microdata", scope: "itemscope", typ: "itemtype", prop: "itemprop", id: []string{"itemid"}}
+	rdfa      = itemSyntax{source: "rdfa", scope: "typeof", typ: "typeof", prop: "property", id: []string{"resource", "about"}}
+)
+
+// entities returns the top-level items: those that are not the property
+// of an enclosing item.
+func (x itemSyntax) entities(doc *goquery.Document, base *url.URL) []models.Entity {
+	var out []models.Entity
+	doc.Find("[" + x.scope + "]").Each(func(i int, s *goquery.Selection) {
+		if _, isProp := s.Attr(x.prop); isProp && s.ParentsFiltered("["+x.scope+"]").Length() > 0 {
+			return
+		}
+		out = append(out, x.entity(s, base))
+	})
+	return out
+}
+
+func (x itemSyntax) entity(s *goquery.Selection, base *url.URL) models.Entity {
+	e := models.Entity{Source: x.source, Properties: map[string]any{}}
+	for _, t := range strings.Fields(s.AttrOr(x.typ, "")) {
+		e.Type = append(e.Type, schemaName(t))
+	}
+	for _, a := range x.id {
+		if id := strings.TrimSpace(s.AttrOr(a, "")); id != "" {
+			e.ID = resolve(base, id)
+			break
+		}
+	}
+	x.collect(s.Children(), base, e.Properties)
+	return e
+}
+
+// collect adds the properties found under nodes, stopping at nested items,
+// which hold their own.
+func (x itemSyntax) collect(nodes *goquery.Selection, base *url.URL, props map[string]any) {
+	nodes.Each(func(i int, c *goquery.Selection) {
+		names, isProp := c.Attr(x.prop)
+		_, isScope := c.Attr(x.scope)
+		if isProp {
+			var v any
+			if isScope {
+				v = x.entity(c, base)
+			} else {
+				v = itemValue(c, base)
+			}
+			for _, n := range strings.Fields(names) {
+				addValue(props, schemaName(n), v)
+			}
+		}
+		if !isScope {
+			x.collect(c.Children(), base, props)
+		}
+	})
+}
+
+// itemValue returns a property's value following the Microdata rules,
+// with a content attribute winning on any element as RDFa has it.
+func itemValue(s *goquery.Selection, base *url.URL) any {
+	if v, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(v)
+	}
+	attr := ""
+	switch goquery.NodeName(s) {
+	case "a", "area", "link":
+		attr = "href"
+	case "img", "audio", "video", "source", "embed", "iframe", "track":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		return strings.TrimSpace(s.AttrOr("value", ""))
+	case "time":
+		if v, ok := s.Attr("datetime"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	if v, ok := s.Attr(attr); ok && attr != "" {
+		return resolve(base, strings.TrimSpace(v))
+	}
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(s.Text(), " "))
+}
+
+func addValue(props map[string]any, name string, v any) {
+	switch old := props[name].(type) {
+	case nil:
+		props[name] = v
+	case []any:
+		props[name] = append(old, v)
+	default:
+		props[name] = []any{old, v}
+	}
+}
+
+// schemaName strips a vocabulary from a type or property name, so
+// "https://schema.org/Product" and "schema:Product" are both "Product".
+func schemaName(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexAny(s, "/#"); i >= 0 {
+		return s[i+1:]
+	}
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+func resolve(base *url.URL, ref string) string {
+	if base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// entityIndex finds entities by @id, for JSON-LD graphs that refer to
+// nodes instead of nesting them.
+type entityIndex map[string]models.Entity
+
+func (ix entityIndex) resolve(e models.Entity) models.Entity {
+	if len(e.Type) == 0 && len(e.Properties) == 0 {
+		if full, ok := ix[e.ID]; ok {
+			return full
+		}
+	}
+	return e
+}
+
+// walk calls fn for every entity in es, nested ones included, depth first.
+func walk(es []models.Entity, fn func(models.Entity)) {
+	for _, e := range es {
+		fn(e)
+		keys := make([]string, 0, len(e.Properties))
+		for k := range e.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := e.Properties[k]
+			vs, ok := v.([]any)
+			if !ok {
+				vs = []any{v}
+			}
+			for _, x := range vs {
+				if c, ok := x.(models.Entity); ok {
+					walk([]models.Entity{c}, fn)
+				}
+			}
+		}
+	}
+}
+
+func decodeStructured(es []models.Entity, base *url.URL) models.Structured {
+	st := models.Structured{Entities: es}
+	ix := entityIndex{}
+	walk(es, func(e models.Entity) {
+		if _, seen := ix[e.ID]; e.ID != "" && !seen && (len(e.Type) > 0 || len(e.Properties) > 0) {
+			ix[e.ID] = e
+		}
+	})
+	walk(es, func(e models.Entity) {
+		switch {
+		case st.Product == nil && e.Is("Product"):
+			st.Product = decodeProduct(e, ix)
+		case st.Article == nil && articleType(e) != "":
+			st.Article = decodeArticle(e, ix)
+		case st.Breadcrumbs == nil && e.Is("BreadcrumbList"):
+			st.Breadcrumbs = decodeBreadcrumbs(e, ix, base)
+		}
+	})
+	return st
+}
+
+func decodeProduct(e models.Entity, ix entityIndex) *models.Product {
+	p := &models.Product{Name: e.Text("name"), SKU: e.Text("sku"), Brand: ix.resolve(first(e.Entities("brand"))).Text("name")}
+	if p.Brand == "" {
+		p.Brand = e.Text("brand")
+	}
+	if offers := e.Entities("offers"); len(offers) > 0 {
+		o := ix.resolve(offers[0])
+		p.Price, p.Currency = o.Text("price"), o.Text("priceCurrency")
+		if p.Price == "" {
+			p.Price = o.Text("lowPrice")
+		}
+		if spec := o.Entities("priceSpecification"); p.Price == "" && len(spec) > 0 {
+			p.Price, p.Currency = spec[0].Text("price"), spec[0].Text("priceCurrency")
+		}
+		p.Availability = schemaName(o.Text("availability"))
+	}
+	if r := ix.resolve(first(e.Entities("aggregateRating"))); len(r.Properties) > 0 {
+		p.Rating, _ = strconv.ParseFloat(r.Text("ratingValue"), 64)
+		if p.ReviewCount, _ = strconv.Atoi(r.Text("reviewCount")); p.ReviewCount == 0 {
+			p.ReviewCount, _ = strconv.Atoi(r.Text("ratingCount"))
+		}
+	}
+	return p
+}
+
+// articleType returns e's Article-like type (Article, NewsArticle,
+// BlogPosting, ...), or "".
+func articleType(e models.Entity) string {
+	for _, t := range e.Type {
+		if strings.HasSuffix(t, "Article") || strings.HasSuffix(t, "Posting") {
+			return t
+		}
+	}
+	return ""
+}
+
+func decodeArticle(e models.Entity, ix entityIndex) *models.Article {
+	a := &models.Article{
+		Type:          articleType(e),
+		Source:        e.Source,
+		Headline:      e.Text("headline"),
+		DatePublished: e.Text("datePublished"),
+		DateModified:  e.Text("dateModified"),
+	}
+	if a.Headline == "" {
+		a.Headline = e.Text("name")
+	}
+	for _, v := range e.Values("author") {
+		name := ""
+		switch v := v.(type) {
+		case string:
+			name = strings.TrimSpace(v)
+		case models.Entity:
+			name = ix.resolve(v).Text("name")
+		}
+		if name != "" && !has(a.Authors, name) {
+			a.Authors = append(a.Authors, name)
+		}
+	}
+	return a
+}
+
+func decodeBreadcrumbs(e models.Entity, ix entityIndex, base *url.URL) []models.Breadcrumb {
+	crumbs := []models.Breadcrumb{}
+	for _, li := range e.Entities("itemListElement") {
+		li = ix.resolve(li)
+		b := models.Breadcrumb{Name: li.Text("name")}
+		b.Position, _ = strconv.Atoi(li.Text("position"))
+		switch item := li.Properties["item"].(type) {
+		case string:
+			b.URL = item
+		case models.Entity:
+			item = ix.resolve(item)
+			if b.URL = item.ID; b.URL == "" {
+				b.URL = item.Text("url")
+			}
+			if b.Name == "" {
+				b.Name = item.Text("name")
+			}
+		}
+		if b.URL != "" {
+			b.URL = resolve(base, b.URL)
+		}
+		crumbs = append(crumbs, b)
+	}
+	sort.SliceStable(crumbs, func(i, j int) bool { return crumbs[i].Position < crumbs[j].Position })
+	return crumbs
+}
+
+func first(es []models.Entity) models.Entity {
+	if len(es) == 0 {
+		return models.Entity{}
+	}
+	return es[0]
+}
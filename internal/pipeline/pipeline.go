@@ -0,0 +1,404 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"brightedge-go-crawler/internal/classifier"
+	"brightedge-go-crawler/internal/crawler"
+	"brightedge-go-crawler/internal/dedup"
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/httpcache"
+	"brightedge-go-crawler/internal/models"
+	"brightedge-go-crawler/internal/parser"
+	"brightedge-go-crawler/internal/scheduler"
+	"brightedge-go-crawler/internal/urlnorm"
+)
+
+// Record is the per-URL output shared by the CLI and every server endpoint.
+type Record struct {
+	Index         int                 `json:"-"`
+	URL           string              `json:"url"`
+	Result        *models.CrawlResult `json:"result,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	ErrorCode     fetcherr.Code       `json:"errorCode,omitempty"`
+	HTTPStatus    int                 `json:"httpStatus,omitempty"`
+	Retryable     bool                `json:"retryable,omitempty"`
+	Attempts      int                 `json:"attempts,omitempty"`
+	AttemptErrors []string            `json:"attemptErrors,omitempty"`
+	Depth         int                 `json:"depth,omitempty"`     // spider mode only
+	ParentURL     string              `json:"parentUrl,omitempty"` // spider mode only
+	DuplicateOf   string              `json:"duplicateOf,omitempty"`
+}
+
+// Summary counts the records of one Run or Spider call.
+type Summary struct {
+	Total      int `json:"total"`
+	Succeeded  int `json:"succeeded"`
+	Failed     int `json:"failed"`
+	Duplicates int `json:"duplicates"`
+}
+
+func (s *Summary) add(r Record) {
+	s.Total++
+	switch {
+	case r.DuplicateOf != "":
+		s.Duplicates++
+	case r.Result != nil:
+		s.Succeeded++
+	default:
+		s.Failed++
+	}
+}
+
+// SetErr fills the error fields of r from err.
+func (r *Record) SetErr(err error) {
+	fe := fetcherr.Classify(err)
+	r.Error, r.ErrorCode, r.HTTPStatus, r.Retryable = fe.Error(), fe.Code, fe.HTTPStatus, fe.Retryable
+}
+
+// Item carries one URL through the stages. Each stage reads what earlier
+// stages left and fills in its own part.
+type Item struct {
+	Index    int
+	URL      string
+	Response *crawler.Response
+	Attempts []crawler.Attempt
+	Page     models.Page
+	Result   models.CrawlResult
+
+	// Done ends the item successfully before the remaining stages run,
+	// e.g. when a 304 was answered from the cache.
+	Done bool
+}
+
+// Stage is one step of the pipeline. Returning an error ends the item.
+type Stage func(ctx context.Context, it *Item) error
+
+// Hooks observe stage execution. Either field may be nil.
+type Hooks struct {
+	Before func(stage string, it *Item)
+	After  func(stage string, it *Item, err error, elapsed time.Duration)
+}
+
+// Config wires the pipeline's dependencies. Zero values get defaults.
+type Config struct {
+	Client     *crawler.HTTPClient
+	Parser     *parser.Parser
+	Classifier *classifier.Classifier
+	Cache      *httpcache.Cache // optional validator cache
+	Scheduler  scheduler.Config
+	Timeout    time.Duration       // per URL, covering retries
+	Topics     int                 // number of TopTopics to keep
+	Normalizer *urlnorm.Normalizer // dedup keys and normalizedUrl; nil uses urlnorm.Default
+}
+
+// Pipeline runs fetch → parse → classify → enrich for a stream of URLs.
+// A nil stage field uses the built-in implementation, so a copy of a
+// Pipeline (see WithClient) keeps working with the copied dependencies.
+type Pipeline struct {
+	Fetch    Stage
+	Parse    Stage
+	Classify Stage
+	Enrich   Stage
+	Hooks    Hooks
+
+	// IncludeLinks adds the full outgoing link list to each result;
+	// link counts are always included.
+	IncludeLinks bool
+
+	// Dedup returns the seen-set that skips repeated URLs, compared by
+	// normalized form, within one Run or Spider call. nil means exact
+	// in-memory dedup; return dedup.Off{} to disable it.
+	Dedup func() dedup.Set
+
+	// EmitDuplicates emits a record with DuplicateOf set for every skipped
+	// URL instead of only counting it in the Summary.
+	EmitDuplicates bool
+
+	client  *crawler.HTTPClient
+	parser  *parser.Parser
+	cl      *classifier.Classifier
+	cache   *httpcache.Cache
+	norm    *urlnorm.Normalizer
+	sched   *scheduler.Scheduler
+	timeout time.Duration
+	topics  int
+}
+
+func New(cfg Config) *Pipeline {
+	if cfg.Client == nil {
+		cfg.Client = crawler.NewHTTPClient(15*time.Second, 5*time.Second, 5*1024*1024)
+	}
+	if cfg.Parser == nil {
+		cfg.Parser = parser.New()
+	}
+	if cfg.Classifier == nil {
+		cfg.Classifier = classifier.New()
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 25 * time.Second
+	}
+	if cfg.Topics <= 0 {
+		cfg.Topics = 15
+	}
+	return &Pipeline{
+		client:  cfg.Client,
+		parser:  cfg.Parser,
+		cl:      cfg.Classifier,
+		cache:   cfg.Cache,
+		norm:    cfg.Normalizer,
+		sched:   scheduler.New(cfg.Scheduler, cfg.Client.CrawlDelay),
+		timeout: cfg.Timeout,
+		topics:  cfg.Topics,
+	}
+}
+
+// WithClient returns a copy of p that fetches with c, e.g. a client
+// reconfigured for one request via crawler.HTTPClient.With.
+func (p *Pipeline) WithClient(c *crawler.HTTPClient) *Pipeline {
+	cp := *p
+	cp.client = c
+	return &cp
+}
+
+// Process runs a single URL through every stage and returns its record.
+func (p *Pipeline) Process(ctx context.Context, index int, rawURL string) Record {
+	rec, _ := p.process(ctx, index, rawURL)
+	return rec
+}
+
+// process is Process that also returns the item, or nil when no stage ran.
+func (p *Pipeline) process(ctx context.Context, index int, rawURL string) (Record, *Item) {
+	rec := Record{Index: index, URL: rawURL}
+	if strings.TrimSpace(rawURL) == "" {
+		rec.SetErr(fetcherr.Errorf(fetcherr.CodeInvalidURL, "empty url"))
+		return rec, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	it := &Item{Index: index, URL: rawURL}
+	defer func() {
+		if it.Response != nil {
+			it.Response.Body.Close()
+		}
+	}()
+
+	stages := []struct {
+		name string
+		run  Stage
+	}{
+		{"fetch", or(p.Fetch, p.fetch)},
+		{"parse", or(p.Parse, p.parse)},
+		{"classify", or(p.Classify, p.classify)},
+		{"enrich", p.Enrich},
+	}
+	var err error
+	for _, st := range stages {
+		if it.Done {
+			break
+		}
+		if st.run == nil {
+			continue
+		}
+		if p.Hooks.Before != nil {
+			p.Hooks.Before(st.name, it)
+		}
+		start := time.Now()
+		err = st.run(ctx, it)
+		if p.Hooks.After != nil {
+			p.Hooks.After(st.name, it, err, time.Since(start))
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	rec.Attempts, rec.AttemptErrors = len(it.Attempts), crawler.AttemptErrors(it.Attempts)
+	if err != nil {
+		rec.SetErr(err)
+		return rec, it
+	}
+	if it.Response != nil && !it.Response.NotModified {
+		// links are kept even without IncludeLinks so a spider can follow a 304;
+		// a failed store only costs a full refetch next time
+		stored := it.Result
+		stored.Links = it.Page.Links
+		_ = p.cache.Store(rawURL, it.Response.Header.Get("ETag"), it.Response.Header.Get("Last-Modified"), stored)
+	}
+	rec.Result = &it.Result
+	return rec, it
+}
+
+// Run processes every URL read from urls through the host-aware scheduler
+// and calls emit once per URL as it completes. Record.Index is the URL's
+// position in the stream. emit is never called concurrently.
+func (p *Pipeline) Run(ctx context.Context, urls <-chan string, emit func(Record)) Summary {
+	report, sum := p.reporter(emit)
+	tasks := make(chan scheduler.Task)
+	fed := make(chan struct{})
+	go func() {
+		defer close(fed)
+		defer close(tasks)
+		seen := p.seenSet()
+		for i := 0; ; i++ {
+			var u string
+			select {
+			case v, ok := <-urls:
+				if !ok {
+					return
+				}
+				u = v
+			case <-ctx.Done():
+				return
+			}
+			if key := p.norm.Key(u); key != "" && !seen.Add(key) {
+				report(Record{Index: i, URL: u, DuplicateOf: key})
+				continue
+			}
+			select {
+			case tasks <- scheduler.Task{Index: i, URL: u}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	p.sched.Run(ctx, tasks, func(t scheduler.Task) {
+		report(p.Process(ctx, t.Index, t.URL))
+	})
+	<-fed
+	return *sum
+}
+
+// reporter serializes emit and tallies every record into the returned Summary.
+// Duplicates are only passed on with EmitDuplicates.
+func (p *Pipeline) reporter(emit func(Record)) (func(Record), *Summary) {
+	var mu sync.Mutex
+	sum := &Summary{}
+	return func(rec Record) {
+		mu.Lock()
+		defer mu.Unlock()
+		sum.add(rec)
+		if rec.DuplicateOf == "" || p.EmitDuplicates {
+			emit(rec)
+		}
+	}, sum
+}
+
+func (p *Pipeline) seenSet() dedup.Set {
+	if p.Dedup == nil {
+		return dedup.NewExact()
+	}
+	return p.Dedup()
+}
+
+// Feed returns a channel that yields urls and is closed afterwards or when ctx ends.
+func Feed(ctx context.Context, urls []string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, u := range urls {
+			select {
+			case ch <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func or(s, def Stage) Stage {
+	if s != nil {
+		return s
+	}
+	return def
+}
+
+func (p *Pipeline) fetch(ctx context.Context, it *Item) error {
+	resp, attempts, err := p.client.FetchWithRetry(ctx, it.URL)
+	it.Attempts = attempts
+	if err != nil {
+		return err
+	}
+	it.Response = resp
+	if resp.NotModified {
+		cr, err := p.cache.Revalidated(it.URL)
+		if err != nil {
+			return err
+		}
+		cr.FetchMs, cr.HTTP = resp.Elapsed.Milliseconds(), resp.HTTPInfo()
+		it.Page.Links = cr.Links
+		if !p.IncludeLinks {
+			cr.Links = nil
+		}
+		it.Result = cr
+		it.Done = true
+	}
+	return nil
+}
+
+func (p *Pipeline) parse(ctx context.Context, it *Item) error {
+	resp := it.Response
+	page, err := p.parser.ExtractURL(resp.Body, resp.ContentType, resp.URL)
+	if err != nil {
+		return err
+	}
+	it.Page = page
+	it.Result = models.CrawlResult{
+		SourceURL:     resp.URL,
+		NormalizedURL: p.norm.Key(resp.URL),
+		FetchMs:       resp.Elapsed.Milliseconds(),
+		HTTP:          resp.HTTPInfo(),
+		Meta:          page.Meta,
+		Content:       page.Content,
+		Indexing:      parser.Indexing(page.Meta, resp.Header.Values("X-Robots-Tag")),
+		LinkStats:     countLinks(page.Links),
+	}
+	if p.IncludeLinks {
+		it.Result.Links = page.Links
+	}
+	if len(page.Structured.Entities) > 0 {
+		it.Result.Structured = &page.Structured
+	}
+	if resp.Truncated() {
+		it.Result.Truncated, it.Result.OriginalContentLength = true, max(resp.DecodedLength(), 0)
+	}
+	return nil
+}
+
+func (p *Pipeline) classify(ctx context.Context, it *Item) error {
+	it.Result.Class = p.cl.Classify(it.Page)
+	it.Result.Topics = p.cl.TopTopics(it.Page.Content.Text, it.Page.Content.Language, p.topics)
+	return nil
+}
+
+func countLinks(links []models.Link) models.LinkStats {
+	st := models.LinkStats{Total: len(links)}
+	for _, l := range links {
+		if l.Internal {
+			st.Internal++
+		} else {
+			st.External++
+		}
+		if IsNofollow(l) {
+			st.Nofollow++
+		}
+	}
+	return st
+}
+
+// IsNofollow reports whether l carries rel=nofollow, ugc or sponsored.
+func IsNofollow(l models.Link) bool {
+	for _, r := range l.Rel {
+		if r == "nofollow" || r == "ugc" || r == "sponsored" {
+			return true
+		}
+	}
+	return false
+}
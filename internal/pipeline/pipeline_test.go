@@ -0,0 +1,222 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"brightedge-go-crawler/internal/crawler"
+	"brightedge-go-crawler/internal/fetcherr"
+	"brightedge-go-crawler/internal/httpcache"
+	"brightedge-go-crawler/internal/scheduler"
+)
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>Shop</title><body><p>Buy now for $10</p></body></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(5*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	p := New(Config{Client: client})
+	var mu sync.Mutex
+	stages := map[string]int{}
+	p.Hooks.After = func(stage string, _ *Item, _ error, _ time.Duration) {
+		mu.Lock()
+		stages[stage]++
+		mu.Unlock()
+	}
+
+	urls := []string{ts.URL + "/a", "", ts.URL + "/missing"}
+	got := make([]Record, len(urls))
+	ctx := context.Background()
+	p.Run(ctx, Feed(ctx, urls), func(r Record) { got[r.Index] = r })
+
+	if got[0].Result == nil || got[0].Result.Class.Label != "product" || got[0].Attempts != 1 {
+		t.Fatalf("unexpected first record: %+v", got[0])
+	}
+	if got[1].ErrorCode != fetcherr.CodeInvalidURL {
+		t.Fatalf("want invalid_url for empty url, got %+v", got[1])
+	}
+	if got[2].ErrorCode != fetcherr.CodeHTTPStatus || got[2].HTTPStatus != 404 {
+		t.Fatalf("want 404 record, got %+v", got[2])
+	}
+	if stages["fetch"] != 2 || stages["parse"] != 1 || stages["classify"] != 1 {
+		t.Fatalf("unexpected stage counts: %v", stages)
+	}
+}
+
+func TestSpider(t *testing.T) {
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b" rel="nofollow">b</a><a href="https://other.example/">x</a><a href="/a#top">a again</a>`,
+		"/a": `<a href="/c">c</a><a href="/">home</a>`,
+		"/c": `<a href="/d">d</a>`,
+		"/d": `<p>too deep</p>`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/c" {
+			w.Header().Set("X-Robots-Tag", "googlebot: nofollow")
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>" + body + "</body></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(5*time.Second, 2*time.Second, 1<<20, crawler.WithRobots(false))
+	p := New(Config{Client: client, Scheduler: scheduler.Config{PerHost: 4}})
+
+	crawl := func(cfg SpiderConfig) map[string]Record {
+		got := map[string]Record{}
+		ctx := context.Background()
+		p.Spider(ctx, Feed(ctx, []string{ts.URL + "/"}), cfg, func(r Record) {
+			got[strings.TrimPrefix(r.URL, ts.URL)] = r
+		})
+		return got
+	}
+
+	got := crawl(SpiderConfig{MaxDepth: 2})
+	if len(got) != 3 {
+		t.Fatalf("want /, /a and /c, got %v", keys(got))
+	}
+	if c := got["/c"]; c.Depth != 2 || c.ParentURL != ts.URL+"/a" {
+		t.Fatalf("unexpected depth/parent for /c: %+v", c)
+	}
+
+	if got = crawl(SpiderConfig{MaxDepth: 5}); len(got) != 3 || !got["/c"].Result.Indexing.NoFollow {
+		t.Fatalf("links on the nofollow page /c were followed: %v", keys(got))
+	}
+
+	got = crawl(SpiderConfig{MaxDepth: 5, FollowNofollow: true, MaxPages: 3})
+	if len(got) != 3 {
+		t.Fatalf("want page budget of 3, got %v", keys(got))
+	}
+	if _, ok := got["/b"]; !ok {
+		t.Fatalf("nofollow link not followed: %v", keys(got))
+	}
+}
+
+func TestSpiderRevalidated(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<html><body><a href="/child">child</a></body></html>`))
+			return
+		}
+		w.Write([]byte("<html><body><p>leaf</p></body></html>"))
+	}))
+	defer ts.Close()
+
+	cache, err := httpcache.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := crawler.NewHTTPClient(5*time.Second, 2*time.Second, 1<<20, crawler.WithRobots(false), crawler.WithValidators(cache))
+	p := New(Config{Client: client, Cache: cache})
+
+	for run := 1; run <= 2; run++ {
+		got := map[string]Record{}
+		ctx := context.Background()
+		p.Spider(ctx, Feed(ctx, []string{ts.URL + "/"}), SpiderConfig{MaxDepth: 2}, func(r Record) {
+			got[strings.TrimPrefix(r.URL, ts.URL)] = r
+		})
+		if _, ok := got["/child"]; len(got) != 2 || !ok {
+			t.Fatalf("run %d: want / and /child, got %v", run, keys(got))
+		}
+		if r := got["/"]; r.Result == nil || r.Result.NotModified != (run == 2) || len(r.Result.Links) != 0 {
+			t.Fatalf("run %d: unexpected result for /: %+v", run, r.Result)
+		}
+	}
+}
+
+func keys(m map[string]Record) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestRunDedup(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(5*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	p := New(Config{Client: client})
+	p.EmitDuplicates = true
+
+	urls := []string{ts.URL + "/a?x=1&y=2", ts.URL + "/b", ts.URL + "/a?y=2&x=1&utm_source=mail#top"}
+	got := make([]Record, len(urls))
+	ctx := context.Background()
+	sum := p.Run(ctx, Feed(ctx, urls), func(r Record) { got[r.Index] = r })
+
+	if sum != (Summary{Total: 3, Succeeded: 2, Duplicates: 1}) {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+	if got[2].DuplicateOf != got[0].Result.NormalizedURL || got[2].Result != nil {
+		t.Fatalf("want third URL marked duplicateOf %s, got %+v", got[0].Result.NormalizedURL, got[2])
+	}
+}
+
+func TestReorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><title>x</title></html>"))
+	}))
+	defer ts.Close()
+
+	client := crawler.NewHTTPClient(5*time.Second, 2*time.Second, 1024, crawler.WithRobots(false))
+	p := New(Config{Client: client, Scheduler: scheduler.Config{Workers: 8, PerHost: 8}})
+
+	urls := []string{ts.URL + "/slow"}
+	for i := 0; i < 30; i++ {
+		urls = append(urls, fmt.Sprintf("%s/%d", ts.URL, i))
+	}
+	const window = 4
+	var got []int
+	ro := NewReorder(window, func(r Record) { got = append(got, r.Index) })
+	maxPending := 0
+	ctx := context.Background()
+	p.Run(ctx, ro.Gate(ctx, Feed(ctx, urls)), func(r Record) {
+		ro.Emit(r)
+		maxPending = max(maxPending, len(ro.pending))
+	})
+	ro.Flush()
+
+	if len(got) != len(urls) {
+		t.Fatalf("want %d records, got %d", len(urls), len(got))
+	}
+	for i, idx := range got {
+		if idx != i {
+			t.Fatalf("records out of order: %v", got)
+		}
+	}
+	if maxPending >= window {
+		t.Fatalf("reorder buffer held %d records, window is %d", maxPending, window)
+	}
+}
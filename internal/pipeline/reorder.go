@@ -0,0 +1,70 @@
+package pipeline
+
+import "context"
+
+// Reorder passes records on in Index order. To bound memory, Gate lets a
+// URL into the pipeline only while fewer than window records are waiting
+// for an earlier one, so a slow URL holds back at most window others.
+type Reorder struct {
+	next    int
+	pending map[int]Record
+	slots   chan struct{}
+	emit    func(Record)
+}
+
+func NewReorder(window int, emit func(Record)) *Reorder {
+	if window <= 0 {
+		window = 1000
+	}
+	return &Reorder{pending: map[int]Record{}, slots: make(chan struct{}, window), emit: emit}
+}
+
+// Gate forwards urls, pausing while the window is full. Use its output as
+// the input of Run; Spider is not supported since it adds URLs of its own.
+func (r *Reorder) Gate(ctx context.Context, urls <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for u := range urls {
+			select {
+			case r.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Emit buffers rec and passes on every record that is now in order. It is
+// meant as Run's emit and, like it, must not be called concurrently.
+func (r *Reorder) Emit(rec Record) {
+	r.pending[rec.Index] = rec
+	for {
+		next, ok := r.pending[r.next]
+		if !ok {
+			return
+		}
+		delete(r.pending, r.next)
+		r.next++
+		<-r.slots
+		r.emit(next)
+	}
+}
+
+// Flush passes on the records still buffered, in order, skipping the gaps
+// left by URLs that never completed, e.g. after cancellation.
+func (r *Reorder) Flush() {
+	for len(r.pending) > 0 {
+		if next, ok := r.pending[r.next]; ok {
+			delete(r.pending, r.next)
+			r.emit(next)
+		}
+		r.next++
+	}
+}
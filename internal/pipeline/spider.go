@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+
+	"brightedge-go-crawler/internal/models"
+	"brightedge-go-crawler/internal/scheduler"
+)
+
+// Scope decides which discovered links a spider follows, relative to the
+// seed the link was reached from.
+type Scope string
+
+const (
+	ScopeHost   Scope = "host"   // same host as the seed, ignoring "www."
+	ScopeDomain Scope = "domain" // same registrable domain, e.g. any *.example.co.uk
+	ScopeRegex  Scope = "regex"  // any URL matching SpiderConfig.Pattern
+)
+
+func ParseScope(s string) (Scope, error) {
+	switch sc := Scope(strings.ToLower(strings.TrimSpace(s))); sc {
+	case "":
+		return ScopeHost, nil
+	case ScopeHost, ScopeDomain, ScopeRegex:
+		return sc, nil
+	}
+	return "", fmt.Errorf("unknown scope %q (want host, domain or regex)", s)
+}
+
+// SpiderConfig controls link following. Zero budgets mean unlimited.
+type SpiderConfig struct {
+	MaxDepth        int // seeds are depth 0
+	Scope           Scope
+	Pattern         *regexp.Regexp // required for ScopeRegex
+	MaxPages        int            // total pages per job, seeds included
+	MaxPagesPerHost int
+	FollowNofollow  bool // also follow rel=nofollow/ugc/sponsored links and links on nofollow pages
+}
+
+type discovered struct {
+	final string // key of the post-redirect URL, marked seen
+	links []spiderLink
+}
+
+type spiderLink struct {
+	url    string
+	depth  int
+	parent string
+	seed   *url.URL
+}
+
+// Spider crawls the seeds read from seeds and follows in-scope links up to
+// cfg.MaxDepth. Every URL is crawled at most once per job. Records carry the
+// depth and parent URL they were reached from; Record.Index is the order in
+// which URLs were admitted. emit is never called concurrently.
+func (p *Pipeline) Spider(ctx context.Context, seeds <-chan string, cfg SpiderConfig, emit func(Record)) Summary {
+	report, sum := p.reporter(emit)
+	tasks := make(chan scheduler.Task)
+	found := make(chan discovered)
+	fed := make(chan struct{})
+
+	var mu sync.Mutex // guards jobs
+	jobs := map[int]spiderLink{}
+
+	go func() {
+		defer close(fed)
+		defer close(tasks)
+		seen := p.seenSet()
+		perHost := map[string]int{}
+		admitted := 0
+		var queue []scheduler.Task
+
+		admit := func(l spiderLink) {
+			key, host := p.spiderKey(l.url)
+			if key != "" {
+				if cfg.MaxPages > 0 && admitted >= cfg.MaxPages {
+					return
+				}
+				if cfg.MaxPagesPerHost > 0 && perHost[host] >= cfg.MaxPagesPerHost {
+					return
+				}
+				if !seen.Add(key) {
+					if l.parent == "" {
+						report(Record{Index: admitted, URL: l.url, DuplicateOf: key})
+						admitted++
+					}
+					return
+				}
+				perHost[host]++
+			}
+			mu.Lock()
+			jobs[admitted] = l
+			mu.Unlock()
+			queue = append(queue, scheduler.Task{Index: admitted, URL: l.url})
+			admitted++
+		}
+
+		in, inflight := seeds, 0
+		for in != nil || len(queue) > 0 || inflight > 0 {
+			var out chan scheduler.Task
+			var next scheduler.Task
+			if len(queue) > 0 {
+				out, next = tasks, queue[0]
+			}
+			select {
+			case u, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				seed, _ := url.Parse(strings.TrimSpace(u))
+				admit(spiderLink{url: u, seed: seed})
+			case out <- next:
+				queue = queue[1:]
+				inflight++
+			case d := <-found:
+				inflight--
+				if d.final != "" {
+					seen.Add(d.final)
+				}
+				for _, l := range d.links {
+					admit(l)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	p.sched.Run(ctx, tasks, func(t scheduler.Task) {
+		mu.Lock()
+		job := jobs[t.Index]
+		delete(jobs, t.Index)
+		mu.Unlock()
+
+		rec, it := p.process(ctx, t.Index, t.URL)
+		rec.Depth, rec.ParentURL = job.depth, job.parent
+
+		var d discovered
+		if rec.Result != nil {
+			d.final, _ = p.spiderKey(rec.Result.SourceURL)
+			// a nofollow robots meta tag or X-Robots-Tag covers every link on the page
+			if job.depth < cfg.MaxDepth && (cfg.FollowNofollow || !rec.Result.Indexing.NoFollow) {
+				for _, l := range it.Page.Links {
+					if !cfg.FollowNofollow && IsNofollow(l) {
+						continue
+					}
+					if !cfg.inScope(job.seed, l) {
+						continue
+					}
+					d.links = append(d.links, spiderLink{url: l.URL, depth: job.depth + 1, parent: t.URL, seed: job.seed})
+				}
+			}
+		}
+
+		report(rec)
+
+		select {
+		case found <- d:
+		case <-ctx.Done():
+		}
+	})
+	<-fed
+	return *sum
+}
+
+func (cfg SpiderConfig) inScope(seed *url.URL, l models.Link) bool {
+	switch cfg.Scope {
+	case ScopeRegex:
+		return cfg.Pattern != nil && cfg.Pattern.MatchString(l.URL)
+	case ScopeDomain:
+		u, err := url.Parse(l.URL)
+		if err != nil || seed == nil {
+			return false
+		}
+		return registrableDomain(u.Hostname()) == registrableDomain(seed.Hostname())
+	}
+	u, err := url.Parse(l.URL)
+	if err != nil || seed == nil {
+		return false
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") ==
+		strings.TrimPrefix(strings.ToLower(seed.Hostname()), "www.")
+}
+
+func registrableDomain(host string) string {
+	host = strings.ToLower(host)
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+// spiderKey returns the normalized dedup key and host of rawURL, or "" if
+// it is not an absolute URL.
+func (p *Pipeline) spiderKey(rawURL string) (key, host string) {
+	key, err := p.norm.Normalize(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	u, _ := url.Parse(key)
+	return key, u.Hostname()
+}
@@ -0,0 +1,184 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxBodySize caps how much of a robots.txt file is read (RFC 9309 asks for at least 500 KiB).
+const maxBodySize = 512 * 1024
+
+// serverErrorTTL is how long a 5xx response keeps a host fully disallowed.
+const serverErrorTTL = 10 * time.Minute
+
+// maxEntries caps how many origins the cache holds.
+const maxEntries = 10000
+
+// fetchTimeout bounds a shared robots.txt download, which outlives the
+// request that started it.
+const fetchTimeout = 30 * time.Second
+
+type entry struct {
+	ready   chan struct{}
+	robots  *Robots
+	err     error
+	expires time.Time
+}
+
+// Cache downloads robots.txt once per origin and keeps it for ttl. Expired
+// entries are dropped when looked up or when the cache fills up; a full
+// cache then also evicts arbitrary entries, which are simply fetched again.
+type Cache struct {
+	client    *http.Client
+	userAgent string
+	ttl       time.Duration
+	max       int
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+func NewCache(client *http.Client, userAgent string, ttl time.Duration) *Cache {
+	return &Cache{
+		client:    client,
+		userAgent: userAgent,
+		ttl:       ttl,
+		max:       maxEntries,
+		entries:   map[string]*entry{},
+	}
+}
+
+func origin(u *url.URL) string { return u.Scheme + "://" + u.Host }
+
+// Get returns the robots.txt for u's origin, fetching it if needed.
+// Concurrent callers for the same origin share a single download, which
+// is not cut short when the caller that started it gives up.
+func (c *Cache) Get(ctx context.Context, u *url.URL) (*Robots, error) {
+	key := origin(u)
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && !e.expires.IsZero() && time.Now().Before(e.expires) {
+		c.mu.Unlock()
+		return e.robots, nil
+	}
+	if !ok || !e.expires.IsZero() {
+		// missing or expired; otherwise a download is in flight
+		if !ok && len(c.entries) >= c.max {
+			c.evict()
+		}
+		e = &entry{ready: make(chan struct{})}
+		c.entries[key] = e
+		go c.download(context.WithoutCancel(ctx), key, e)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.robots, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Cache) download(ctx context.Context, key string, e *entry) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	rb, ttl, err := c.fetch(ctx, key)
+
+	c.mu.Lock()
+	e.robots, e.err = rb, err
+	if err != nil {
+		// do not cache transport failures
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+	} else {
+		e.expires = time.Now().Add(ttl)
+	}
+	c.mu.Unlock()
+	close(e.ready)
+}
+
+// evict drops expired entries and, if the cache is still full, enough
+// others to bring it to three quarters of its size. Downloads in flight are
+// kept. c.mu must be held.
+func (c *Cache) evict() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for k, e := range c.entries {
+		if len(c.entries) <= c.max*3/4 {
+			break
+		}
+		if !e.expires.IsZero() {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Allowed reports whether the cache's user agent may fetch u.
+func (c *Cache) Allowed(ctx context.Context, u *url.URL) (bool, error) {
+	rb, err := c.Get(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rb.Allowed(c.userAgent, path), nil
+}
+
+// CrawlDelay returns the Crawl-delay for u's origin if its robots.txt is
+// already cached. It never triggers a download.
+func (c *Cache) CrawlDelay(u *url.URL) time.Duration {
+	key := origin(u)
+	c.mu.Lock()
+	var rb *Robots
+	if e, ok := c.entries[key]; ok && !e.expires.IsZero() {
+		if time.Now().Before(e.expires) {
+			rb = e.robots
+		} else {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+	if rb == nil {
+		return 0
+	}
+	return rb.CrawlDelay(c.userAgent)
+}
+
+// fetch downloads and parses robots.txt. Per RFC 9309, 4xx means everything
+// is allowed and 5xx means everything is disallowed.
+func (c *Cache) fetch(ctx context.Context, base string) (*Robots, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/robots.txt", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// a read error still leaves whatever rules were parsed so far
+		rb, _ := Parse(io.LimitReader(resp.Body, maxBodySize))
+		return rb, c.ttl, nil
+	case resp.StatusCode >= 500:
+		return DisallowAll(), min(c.ttl, serverErrorTTL), nil
+	default:
+		return AllowAll(), c.ttl, nil
+	}
+}
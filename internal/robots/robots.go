@@ -0,0 +1,181 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is a single Allow or Disallow line. Patterns may contain '*' wildcards
+// and a trailing '$' anchor.
+type Rule struct {
+	Allow   bool
+	Pattern string
+}
+
+// Group holds the rules that apply to one or more user agents.
+type Group struct {
+	Agents     []string
+	Rules      []Rule
+	CrawlDelay time.Duration
+}
+
+// Robots is a parsed robots.txt file.
+type Robots struct {
+	Groups   []Group
+	Sitemaps []string
+}
+
+// AllowAll returns a Robots that permits every path.
+func AllowAll() *Robots { return &Robots{} }
+
+// DisallowAll returns a Robots that blocks every path for every agent.
+func DisallowAll() *Robots {
+	return &Robots{Groups: []Group{{Agents: []string{"*"}, Rules: []Rule{{Allow: false, Pattern: "/"}}}}}
+}
+
+// Parse reads a robots.txt body. Unknown directives and malformed lines are ignored.
+func Parse(r io.Reader) (*Robots, error) {
+	out := &Robots{}
+	var cur *Group
+	inAgents := false // true while reading consecutive user-agent lines
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 512*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				out.Groups = append(out.Groups, Group{})
+				cur = &out.Groups[len(out.Groups)-1]
+				inAgents = true
+			}
+			cur.Agents = append(cur.Agents, strings.ToLower(val))
+		case "allow", "disallow":
+			inAgents = false
+			if cur == nil || val == "" {
+				continue
+			}
+			cur.Rules = append(cur.Rules, Rule{Allow: key == "allow", Pattern: val})
+		case "crawl-delay":
+			inAgents = false
+			if cur == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(val, 64); err == nil && secs >= 0 {
+				cur.CrawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			// sitemap lines are global and do not end a group
+			if val != "" {
+				out.Sitemaps = append(out.Sitemaps, val)
+			}
+		default:
+			inAgents = false
+		}
+	}
+	return out, sc.Err()
+}
+
+// rules merges every group matching agent, falling back to the "*" groups.
+func (r *Robots) rules(agent string) ([]Rule, time.Duration) {
+	token := strings.ToLower(agent)
+	if i := strings.IndexByte(token, '/'); i >= 0 {
+		token = token[:i]
+	}
+	token = strings.TrimSpace(token)
+
+	var specific, wildcard []Rule
+	var specificDelay, wildcardDelay time.Duration
+	foundSpecific := false
+	for _, g := range r.Groups {
+		isSpecific, isWildcard := false, false
+		for _, a := range g.Agents {
+			isSpecific = isSpecific || a == token
+			isWildcard = isWildcard || a == "*"
+		}
+		if isSpecific {
+			foundSpecific = true
+			specific = append(specific, g.Rules...)
+			specificDelay = max(specificDelay, g.CrawlDelay)
+		} else if isWildcard {
+			wildcard = append(wildcard, g.Rules...)
+			wildcardDelay = max(wildcardDelay, g.CrawlDelay)
+		}
+	}
+	if foundSpecific {
+		return specific, specificDelay
+	}
+	return wildcard, wildcardDelay
+}
+
+// Allowed reports whether agent may fetch path (path plus optional "?query").
+// The longest matching pattern wins; on a tie Allow wins.
+func (r *Robots) Allowed(agent, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	rules, _ := r.rules(agent)
+	allowed, best := true, -1
+	for _, rule := range rules {
+		if !match(rule.Pattern, path) {
+			continue
+		}
+		n := len(rule.Pattern)
+		if n > best || (n == best && rule.Allow) {
+			best, allowed = n, rule.Allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay that applies to agent, or zero.
+func (r *Robots) CrawlDelay(agent string) time.Duration {
+	_, d := r.rules(agent)
+	return d
+}
+
+// match reports whether path matches a robots pattern. Patterns are
+// prefix matches unless they end in '$'.
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	for i, p := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(rest, p)
+		}
+		j := strings.Index(rest, p)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(p):]
+	}
+	return true
+}
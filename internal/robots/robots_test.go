@@ -0,0 +1,135 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleRobots = `# comment
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: brightedge-go-crawler
+User-agent: other
+Disallow: /search?q=*
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestParseAndMatch(t *testing.T) {
+	rb, err := Parse(strings.NewReader(sampleRobots))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rb.Sitemaps) != 1 || len(rb.Groups) != 2 {
+		t.Fatalf("unexpected parse result: %#v", rb)
+	}
+
+	cases := []struct {
+		agent, path string
+		want        bool
+	}{
+		{"somebot/2.0", "/private/x", false},
+		{"somebot/2.0", "/private/open/x", true},
+		{"somebot/2.0", "/doc.pdf", false},
+		{"somebot/2.0", "/doc.pdf?x=1", true},
+		{"somebot/2.0", "/robots.txt", true},
+		{"brightedge-go-crawler/1.0", "/private/x", true},
+		{"brightedge-go-crawler/1.0", "/search?q=go", false},
+	}
+	for _, c := range cases {
+		if got := rb.Allowed(c.agent, c.path); got != c.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", c.agent, c.path, got, c.want)
+		}
+	}
+	if d := rb.CrawlDelay("brightedge-go-crawler/1.0"); d != 500*time.Millisecond {
+		t.Fatalf("crawl delay = %v", d)
+	}
+	if d := rb.CrawlDelay("somebot"); d != 2*time.Second {
+		t.Fatalf("crawl delay = %v", d)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestCacheBounded(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(sampleRobots)), Request: r}, nil
+	})}
+	c := NewCache(client, "brightedge-go-crawler", time.Hour)
+	c.max = 4
+	for i := 0; i < 10; i++ {
+		u, _ := url.Parse(fmt.Sprintf("https://host%d.example/", i))
+		if _, err := c.Get(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.entries) > c.max {
+			t.Fatalf("cache grew to %d entries, cap %d", len(c.entries), c.max)
+		}
+	}
+
+	c = NewCache(client, "brightedge-go-crawler", time.Millisecond)
+	u, _ := url.Parse("https://a.example/")
+	if _, err := c.Get(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if d := c.CrawlDelay(u); d != 0 || len(c.entries) != 0 {
+		t.Fatalf("expired entry kept: delay %v, %d entries", d, len(c.entries))
+	}
+}
+
+func TestCacheSharedDownload(t *testing.T) {
+	release := make(chan struct{})
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(sampleRobots)), Request: r}, nil
+	})}
+	c := NewCache(client, "brightedge-go-crawler", time.Hour)
+	u, _ := url.Parse("https://a.example/")
+
+	first, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Get(first, u)
+		errc <- err
+	}()
+	for {
+		c.mu.Lock()
+		_, started := c.entries[origin(u)]
+		c.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	got := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.Background(), u)
+		got <- err
+	}()
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("first caller: want context.Canceled, got %v", err)
+	}
+	close(release)
+	if err := <-got; err != nil {
+		t.Fatalf("second caller failed with the first one's context: %v", err)
+	}
+}
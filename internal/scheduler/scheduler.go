@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config controls global and per-host concurrency.
+type Config struct {
+	Workers     int     // global worker pool size
+	PerHost     int     // max concurrent requests to a single host
+	PerHostRate float64 // sustained requests per second per host; 0 disables the limit
+	Burst       int     // token bucket size for PerHostRate
+	MaxPending  int     // queued tasks before input reading pauses
+}
+
+func DefaultConfig() Config {
+	return Config{Workers: 10, PerHost: 2, PerHostRate: 2, Burst: 2, MaxPending: 10000}
+}
+
+// Task is one URL to crawl. Index is the caller's position in its input.
+type Task struct {
+	Index int
+	URL   string
+}
+
+// DelayFunc returns the robots.txt Crawl-delay for rawURL's host. It must be
+// cheap and must not block on the network.
+type DelayFunc func(rawURL string) time.Duration
+
+// Scheduler sits between a URL stream and the fetcher. It keeps a FIFO per
+// host so one busy host never starves the worker pool of other hosts' URLs.
+// Per-host limits are shared by every Run call on the same Scheduler, so
+// concurrent batches do not multiply the load on a host.
+type Scheduler struct {
+	cfg   Config
+	delay DelayFunc
+
+	mu      sync.Mutex
+	hosts   map[string]*host // hosts with requests running or recently run
+	sweepAt int              // size of hosts that triggers the next sweep
+	changed chan struct{}    // closed and replaced whenever a request ends
+}
+
+// minSweep is the smallest host table that is swept for idle entries.
+const minSweep = 1024
+
+func New(cfg Config, delay DelayFunc) *Scheduler {
+	def := DefaultConfig()
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.PerHost <= 0 {
+		cfg.PerHost = def.PerHost
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = def.MaxPending
+	}
+	return &Scheduler{cfg: cfg, delay: delay, hosts: map[string]*host{}, sweepAt: minSweep, changed: make(chan struct{})}
+}
+
+type host struct {
+	active int
+
+	// crawl-delay is unknown until the first request to the host finishes,
+	// so a host runs one request at a time until probed.
+	probed bool
+	delay  time.Duration
+	next   time.Time
+
+	tokens float64
+	last   time.Time
+}
+
+// host returns the state for key, first dropping idle hosts if the table
+// has doubled since the last sweep. s.mu must be held.
+func (s *Scheduler) host(key string, now time.Time) *host {
+	h := s.hosts[key]
+	if h == nil {
+		if len(s.hosts) >= s.sweepAt {
+			for k, h := range s.hosts {
+				if s.idle(h, now) {
+					delete(s.hosts, k)
+				}
+			}
+			s.sweepAt = max(2*len(s.hosts), minSweep)
+		}
+		h = &host{tokens: float64(s.cfg.Burst), last: now}
+		s.hosts[key] = h
+	}
+	return h
+}
+
+// idle reports whether h could be forgotten without loosening its limits:
+// nothing runs, its crawl-delay has passed and its bucket is full again.
+func (s *Scheduler) idle(h *host, now time.Time) bool {
+	if h.active > 0 || now.Before(h.next) {
+		return false
+	}
+	return s.cfg.PerHostRate <= 0 || h.tokens+now.Sub(h.last).Seconds()*s.cfg.PerHostRate >= float64(s.cfg.Burst)
+}
+
+// ready reports whether h may start a request now, or when it may next try.
+func (s *Scheduler) ready(h *host, now time.Time) (bool, time.Time) {
+	limit := s.cfg.PerHost
+	if !h.probed || h.delay > 0 {
+		limit = 1
+	}
+	if h.active >= limit {
+		return false, time.Time{}
+	}
+	if now.Before(h.next) {
+		return false, h.next
+	}
+	if s.cfg.PerHostRate > 0 {
+		h.tokens = min(float64(s.cfg.Burst), h.tokens+now.Sub(h.last).Seconds()*s.cfg.PerHostRate)
+		h.last = now
+		if h.tokens < 1 {
+			wait := time.Duration((1 - h.tokens) / s.cfg.PerHostRate * float64(time.Second))
+			return false, now.Add(wait)
+		}
+	}
+	return true, time.Time{}
+}
+
+// Run calls fn for every task read from in, honoring the configured limits,
+// and returns once in is closed and every call has returned. If ctx is
+// cancelled, queued tasks are dropped and Run waits only for running calls.
+func (s *Scheduler) Run(ctx context.Context, in <-chan Task, fn func(Task)) {
+	type finished struct {
+		key string
+		url string
+	}
+
+	queues := map[string][]Task{}
+	var ring []string // hosts with queued tasks, served round-robin
+	rr := 0
+	done := make(chan finished)
+	running, pending := 0, 0
+	closed, cancelled := false, false
+	ctxDone := ctx.Done()
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		if !cancelled && len(ring) > 0 {
+			now := time.Now()
+			var wake time.Time
+			for progress := true; progress && running < s.cfg.Workers; {
+				progress = false
+				for i := range ring {
+					if running >= s.cfg.Workers {
+						break
+					}
+					key := ring[(rr+i)%len(ring)]
+					if len(queues[key]) == 0 {
+						continue
+					}
+					h := s.host(key, now)
+					ok, at := s.ready(h, now)
+					if !ok {
+						if !at.IsZero() && (wake.IsZero() || at.Before(wake)) {
+							wake = at
+						}
+						continue
+					}
+					t := queues[key][0]
+					queues[key] = queues[key][1:]
+					h.active++
+					h.tokens--
+					if h.delay > 0 {
+						h.next = now.Add(h.delay)
+					}
+					pending--
+					running++
+					progress = true
+					go func(key string) {
+						fn(t)
+						done <- finished{key: key, url: t.URL}
+					}(key)
+				}
+				rr++
+			}
+			kept := ring[:0]
+			for _, key := range ring {
+				if len(queues[key]) > 0 {
+					kept = append(kept, key)
+				} else {
+					delete(queues, key)
+				}
+			}
+			ring = kept
+			if !wake.IsZero() {
+				timer.Reset(time.Until(wake))
+			}
+		}
+		s.mu.Unlock()
+
+		if running == 0 && (cancelled || (closed && pending == 0)) {
+			return
+		}
+
+		input := in
+		if closed || cancelled || pending >= s.cfg.MaxPending {
+			input = nil
+		}
+		select {
+		case t, ok := <-input:
+			if !ok {
+				closed = true
+				continue
+			}
+			key := hostKey(t.URL)
+			if len(queues[key]) == 0 {
+				ring = append(ring, key)
+			}
+			queues[key] = append(queues[key], t)
+			pending++
+		case f := <-done:
+			running--
+			s.finish(f.key, f.url)
+		case <-changed:
+		case <-timer.C:
+		case <-ctxDone:
+			cancelled = true
+			ctxDone = nil
+		}
+	}
+}
+
+// finish releases a request to key and wakes Run calls waiting on a host.
+func (s *Scheduler) finish(key, rawURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.hosts[key]
+	h.active--
+	if !h.probed {
+		h.probed = true
+		if s.delay != nil && key != "" {
+			h.delay = s.delay(rawURL)
+		}
+	}
+	if h.delay > 0 {
+		h.next = time.Now().Add(h.delay)
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func hostKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPerHostLimit(t *testing.T) {
+	s := New(Config{Workers: 8, PerHost: 2}, nil)
+	in := make(chan Task)
+	go func() {
+		for i := 0; i < 20; i++ {
+			in <- Task{Index: i, URL: "https://a.example/" + fmt.Sprint(i)}
+		}
+		for i := 20; i < 30; i++ {
+			in <- Task{Index: i, URL: fmt.Sprintf("https://host%d.example/", i)}
+		}
+		close(in)
+	}()
+
+	var mu sync.Mutex
+	active, peak, ran := 0, 0, 0
+	s.Run(context.Background(), in, func(t Task) {
+		mu.Lock()
+		ran++
+		isA := t.Index < 20
+		if isA {
+			active++
+			peak = max(peak, active)
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		if isA {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}
+	})
+	if ran != 30 {
+		t.Fatalf("ran %d tasks, want 30", ran)
+	}
+	if peak > 2 {
+		t.Fatalf("peak per-host concurrency %d, want <= 2", peak)
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	s := New(Config{Workers: 4, PerHost: 4}, func(string) time.Duration { return 30 * time.Millisecond })
+	in := make(chan Task, 3)
+	for i := 0; i < 3; i++ {
+		in <- Task{Index: i, URL: "https://slow.example/"}
+	}
+	close(in)
+
+	start := time.Now()
+	s.Run(context.Background(), in, func(Task) {})
+	if el := time.Since(start); el < 60*time.Millisecond {
+		t.Fatalf("crawl-delay not honored: finished in %v", el)
+	}
+}
+
+func TestSharedHostLimit(t *testing.T) {
+	s := New(Config{Workers: 8, PerHost: 2, MaxPending: 10}, nil)
+	var mu sync.Mutex
+	active, peak := 0, 0
+	fn := func(Task) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for r := 0; r < 3; r++ {
+		in := make(chan Task)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(context.Background(), in, fn)
+		}()
+		go func() {
+			for i := 0; i < 10; i++ {
+				in <- Task{Index: i, URL: "https://a.example/" + fmt.Sprint(i)}
+			}
+			close(in)
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Fatalf("peak concurrency across runs %d, want <= 2", peak)
+	}
+
+	in := make(chan Task)
+	go func() {
+		for i := 0; i < 5000; i++ {
+			in <- Task{Index: i, URL: fmt.Sprintf("https://host%d.example/", i)}
+		}
+		close(in)
+	}()
+	s.Run(context.Background(), in, func(Task) {})
+	if n := len(s.hosts); n > minSweep+s.cfg.Workers {
+		t.Fatalf("host table kept %d entries", n)
+	}
+}
@@ -0,0 +1,228 @@
+// Package urlnorm canonicalizes URLs so that equivalent spellings of the
+// same resource share one key.
+package urlnorm
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// DefaultStripParams are the tracking parameters removed by Default.
+// A trailing "*" matches any parameter with that prefix.
+var DefaultStripParams = []string{"utm_*", "gclid", "fbclid"}
+
+var Default = New(DefaultStripParams...)
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// Normalizer rewrites URLs into canonical form. A nil *Normalizer behaves
+// like Default.
+type Normalizer struct {
+	strip []string
+}
+
+// New returns a Normalizer that removes the given query parameters,
+// matched case-insensitively.
+func New(stripParams ...string) *Normalizer {
+	n := &Normalizer{}
+	for _, p := range stripParams {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			n.strip = append(n.strip, p)
+		}
+	}
+	return n
+}
+
+// Normalize lowercases the scheme and host, converts IDNs to punycode,
+// drops default ports, the fragment and stripped parameters, resolves dot
+// segments, normalizes percent-encoding and sorts the query by key.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	if n == nil {
+		n = Default
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("not an absolute URL")
+	}
+	scheme := strings.ToLower(u.Scheme)
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	port := u.Port()
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	if port != "" || strings.Contains(host, ":") {
+		host = net.JoinHostPort(host, port)
+		host = strings.TrimSuffix(host, ":")
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	b.WriteString(host)
+
+	path := removeDotSegments(escape(u.EscapedPath(), pathChars))
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+
+	if q := n.query(u.RawQuery); q != "" {
+		b.WriteByte('?')
+		b.WriteString(q)
+	}
+	return b.String(), nil
+}
+
+// Key returns the normalized form of rawURL, or rawURL trimmed when it
+// cannot be normalized. It is the dedup key for crawls and caches.
+func (n *Normalizer) Key(rawURL string) string {
+	if s, err := n.Normalize(rawURL); err == nil {
+		return s
+	}
+	return strings.TrimSpace(rawURL)
+}
+
+func Normalize(rawURL string) (string, error) { return Default.Normalize(rawURL) }
+
+func Key(rawURL string) string { return Default.Key(rawURL) }
+
+func (n *Normalizer) query(raw string) string {
+	type param struct{ key, value string }
+	var params []param
+	for _, part := range strings.Split(raw, "&") {
+		if part == "" {
+			continue
+		}
+		k, v, hasValue := strings.Cut(part, "=")
+		k = escape(k, queryKeyChars)
+		if n.stripped(k) {
+			continue
+		}
+		if hasValue {
+			v = "=" + escape(v, queryValueChars)
+		}
+		params = append(params, param{k, v})
+	}
+	sort.SliceStable(params, func(i, j int) bool { return params[i].key < params[j].key })
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.key + p.value
+	}
+	return strings.Join(parts, "&")
+}
+
+func (n *Normalizer) stripped(key string) bool {
+	if dec, err := url.QueryUnescape(key); err == nil {
+		key = dec
+	}
+	key = strings.ToLower(key)
+	for _, s := range n.strip {
+		if prefix, ok := strings.CutSuffix(s, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Characters left unescaped in each component besides the unreserved set.
+const (
+	pathChars       = "!$&'()*+,;=:@/"
+	queryKeyChars   = "!$'()*+,;:@/?"
+	queryValueChars = "!$'()*+,;:@/?="
+)
+
+func unreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// escape decodes percent-encoded unreserved characters, uppercases the hex
+// digits of the remaining escapes and encodes anything else outside
+// unreserved and allowed.
+func escape(s, allowed string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			d := unhex(s[i+1])<<4 | unhex(s[i+2])
+			i += 2
+			if unreserved(d) {
+				b.WriteByte(d)
+				continue
+			}
+			b.WriteByte('%')
+			b.WriteByte(hex[d>>4])
+			b.WriteByte(hex[d&15])
+			continue
+		}
+		if unreserved(c) || (c != '%' && strings.IndexByte(allowed, c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// removeDotSegments resolves "." and ".." as in RFC 3986 section 5.2.4.
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	segs := strings.Split(p, "/")
+	out := make([]string, 0, len(segs))
+	for i, s := range segs {
+		last := i == len(segs)-1
+		switch s {
+		case ".":
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, s)
+			continue
+		}
+		if last {
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
@@ -0,0 +1,37 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"HTTP://Example.com:80/a?b=2&a=1#x", "http://example.com/a?a=1&b=2"},
+		{"http://example.com/a?a=1&b=2", "http://example.com/a?a=1&b=2"},
+		{"https://example.com:443", "https://example.com/"},
+		{"https://example.com:8443/", "https://example.com:8443/"},
+		{"https://example.com/a/./b/../c/", "https://example.com/a/c/"},
+		{"https://example.com/../a/..", "https://example.com/"},
+		{"https://example.com/%7euser/%e2%82%ac?q=%41%2f", "https://example.com/~user/%E2%82%AC?q=A%2F"},
+		{"https://example.com/a b", "https://example.com/a%20b"},
+		{"https://example.com/?utm_source=x&id=3&UTM_Medium=y&gclid=1&fbclid=2", "https://example.com/?id=3"},
+		{"https://Bücher.example/", "https://xn--bcher-kva.example/"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"https://example.com./?b&a=", "https://example.com/?a=&b"},
+	}
+	for _, c := range cases {
+		got, err := Normalize(c.in)
+		if err != nil {
+			t.Errorf("%s: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.in, got, c.want)
+		}
+	}
+
+	if _, err := Normalize("/relative"); err == nil {
+		t.Error("want error for relative URL")
+	}
+	if got := New("sid").Key("https://example.com/?utm_source=x&sid=1"); got != "https://example.com/?utm_source=x" {
+		t.Errorf("custom strip list: got %s", got)
+	}
+}
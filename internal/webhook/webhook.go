@@ -0,0 +1,125 @@
+// Package webhook signs callback payloads and delivers them with retries.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on every delivery. The signature is "sha256=" followed by the
+// hex HMAC-SHA256, keyed by the job's secret, of the timestamp, a '.', and
+// the body; receivers should also reject stale timestamps.
+const (
+	HeaderEvent     = "X-Crawler-Event"
+	HeaderDelivery  = "X-Crawler-Delivery"
+	HeaderTimestamp = "X-Crawler-Timestamp"
+	HeaderSignature = "X-Crawler-Signature"
+)
+
+// Sign returns the HeaderSignature value for body sent at unix time ts.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a received delivery.
+func Verify(secret string, h http.Header, body []byte) bool {
+	ts, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(h.Get(HeaderSignature)), []byte(Sign(secret, ts, body)))
+}
+
+// Poster sends one POST and reports the response status, typically a
+// *crawler.HTTPClient so deliveries obey the crawler's network policy.
+type Poster interface {
+	Post(ctx context.Context, rawURL string, header http.Header, body []byte) (int, error)
+}
+
+// Policy controls how often a delivery is tried.
+type Policy struct {
+	MaxAttempts int           // total tries including the first
+	BaseDelay   time.Duration // delay before the second try, doubled on each retry
+	MaxDelay    time.Duration // cap for the computed backoff
+}
+
+func DefaultPolicy() Policy {
+	return Policy{MaxAttempts: 6, BaseDelay: time.Second, MaxDelay: 5 * time.Minute}
+}
+
+func (p Policy) backoff(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// Attempt is the outcome of one try.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Sender delivers payloads, retrying failures with exponential backoff.
+type Sender struct {
+	post   Poster
+	policy Policy
+}
+
+func NewSender(post Poster, policy Policy) *Sender {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	return &Sender{post: post, policy: policy}
+}
+
+// Deliver POSTs body to url until it is answered with a 2xx, the attempts
+// run out or ctx ends. id should stay the same across retries so receivers
+// can drop repeats. It returns every attempt and an error unless the last
+// one succeeded.
+func (s *Sender) Deliver(ctx context.Context, url, secret, event, id string, body []byte) ([]Attempt, error) {
+	var attempts []Attempt
+	for n := 1; ; n++ {
+		now := time.Now().UTC()
+		h := http.Header{}
+		h.Set("Content-Type", "application/json")
+		h.Set(HeaderEvent, event)
+		h.Set(HeaderDelivery, id)
+		h.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+		h.Set(HeaderSignature, Sign(secret, now.Unix(), body))
+
+		status, err := s.post.Post(ctx, url, h, body)
+		a := Attempt{At: now, StatusCode: status}
+		switch {
+		case err != nil:
+			a.Error = err.Error()
+		case status < 200 || status > 299:
+			err = fmt.Errorf("%d %s", status, strings.ToLower(http.StatusText(status)))
+			a.Error = err.Error()
+		}
+		attempts = append(attempts, a)
+		if err == nil || n >= s.policy.MaxAttempts || ctx.Err() != nil {
+			return attempts, err
+		}
+		t := time.NewTimer(s.policy.backoff(n))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return attempts, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type posterFunc func(header http.Header, body []byte) (int, error)
+
+func (f posterFunc) Post(_ context.Context, _ string, header http.Header, body []byte) (int, error) {
+	return f(header, body)
+}
+
+func TestDeliver(t *testing.T) {
+	var tries int
+	down := false
+	s := NewSender(posterFunc(func(h http.Header, body []byte) (int, error) {
+		tries++
+		if !Verify("k", h, body) || Verify("other", h, body) {
+			t.Errorf("bad signature %q", h.Get(HeaderSignature))
+		}
+		switch {
+		case down:
+			return http.StatusBadGateway, nil
+		case tries == 1:
+			return 0, errors.New("connection refused")
+		case tries == 2:
+			return http.StatusServiceUnavailable, nil
+		}
+		return http.StatusNoContent, nil
+	}), Policy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	attempts, err := s.Deliver(context.Background(), "http://hook", "k", "job.completed", "d1", []byte(`{}`))
+	if err != nil || len(attempts) != 3 || attempts[1].StatusCode != 503 || attempts[2].Error != "" {
+		t.Fatalf("unexpected attempts %+v, %v", attempts, err)
+	}
+
+	down = true
+	if attempts, err := s.Deliver(context.Background(), "http://hook", "k", "e", "d2", nil); err == nil || len(attempts) != 3 {
+		t.Fatalf("want 3 failed attempts, got %+v, %v", attempts, err)
+	}
+}